@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type errDenied struct {
+	name string
+	gvk  schema.GroupVersionKind
+	xr   string
+}
+
+func (e errDenied) Error() string {
+	return fmt.Sprintf("composite resource %s %q is not allowed to call server function %q", e.gvk.Kind, e.xr, e.name)
+}
+
+// IsErrorDenied returns true if a ServerFunction call was rejected because
+// the access policy of the ServerFunction does not allow the composite
+// resource.
+func IsErrorDenied(err error) bool {
+	return errors.As(err, &errDenied{})
+}
+
+// An AccessRule allows composite resources to call a ServerFunction.
+type AccessRule interface {
+	// Allows returns true if the composite resource xr may call the
+	// ServerFunction.
+	Allows(xr *unstructured.Unstructured) bool
+}
+
+// AccessRuleFn is a function that implements AccessRule.
+type AccessRuleFn func(xr *unstructured.Unstructured) bool
+
+// Allows implements AccessRule.
+func (fn AccessRuleFn) Allows(xr *unstructured.Unstructured) bool {
+	return fn(xr)
+}
+
+// AllowKinds allows composite resources of the given kinds. The version of a
+// GroupVersionKind may be empty to allow all versions.
+func AllowKinds(gvks ...schema.GroupVersionKind) AccessRule {
+	return AccessRuleFn(func(xr *unstructured.Unstructured) bool {
+		actual := xr.GroupVersionKind()
+		for _, gvk := range gvks {
+			if gvk.GroupKind() == actual.GroupKind() && (gvk.Version == "" || gvk.Version == actual.Version) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// AllowSelector allows composite resources whose labels match sel.
+func AllowSelector(sel labels.Selector) AccessRule {
+	return AccessRuleFn(func(xr *unstructured.Unstructured) bool {
+		return sel.Matches(labels.Set(xr.GetLabels()))
+	})
+}
+
+// WithAccessPolicy restricts which composite resources may call a
+// ServerFunction. A call is allowed if any of the rules allows the composite
+// resource. Denied calls return a fatal result.
+//
+// ServerFunctions without access policy may be called by all composite
+// resources.
+func WithAccessPolicy(rules ...AccessRule) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.access = append(fn.access, rules...)
+	}
+}
+
+// checkAccess returns an error if the access policy of the named
+// ServerFunction does not allow the composite resource of req.
+func checkAccess(name string, rules []AccessRule, req *fnapi.RunFunctionRequest) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	xr := &unstructured.Unstructured{}
+	if err := resource.AsObject(req.GetObserved().GetComposite().GetResource(), xr); err != nil {
+		return errors.Wrap(err, "cannot decode composite resource")
+	}
+	for _, r := range rules {
+		if r.Allows(xr) {
+			return nil
+		}
+	}
+	return errDenied{name: name, gvk: xr.GroupVersionKind(), xr: xr.GetName()}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	srv := NewServer(
+		WithFunction("public", noop),
+		WithFunction("internal", noop, WithAccessPolicy(
+			AllowKinds(schema.GroupVersionKind{Group: "platform.example.org", Kind: "XNetwork"}),
+			AllowSelector(labels.SelectorFromSet(labels.Set{"tenant": "platform"})),
+		)),
+		WithFunction("versioned", noop, WithAccessPolicy(
+			AllowKinds(schema.GroupVersionKind{Group: "platform.example.org", Version: "v1", Kind: "XNetwork"}),
+		)),
+	)
+
+	type xr struct {
+		apiVersion string
+		kind       string
+		labels     map[string]any
+	}
+	cases := map[string]struct {
+		function string
+		xr       xr
+		denied   bool
+	}{
+		"NoPolicy": {
+			function: "public",
+			xr:       xr{apiVersion: "tenant.example.org/v1", kind: "XApp"},
+		},
+		"AllowedKind": {
+			function: "internal",
+			xr:       xr{apiVersion: "platform.example.org/v1alpha1", kind: "XNetwork"},
+		},
+		"AllowedLabels": {
+			function: "internal",
+			xr:       xr{apiVersion: "tenant.example.org/v1", kind: "XApp", labels: map[string]any{"tenant": "platform"}},
+		},
+		"Denied": {
+			function: "internal",
+			xr:       xr{apiVersion: "tenant.example.org/v1", kind: "XApp", labels: map[string]any{"tenant": "team-a"}},
+			denied:   true,
+		},
+		"AllowedVersion": {
+			function: "versioned",
+			xr:       xr{apiVersion: "platform.example.org/v1", kind: "XNetwork"},
+		},
+		"DeniedVersion": {
+			function: "versioned",
+			xr:       xr{apiVersion: "platform.example.org/v1alpha1", kind: "XNetwork"},
+			denied:   true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestRequest(t, tc.function, nil)
+			metadata := map[string]any{"name": "example"}
+			if tc.xr.labels != nil {
+				metadata["labels"] = tc.xr.labels
+			}
+			req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+				"apiVersion": tc.xr.apiVersion,
+				"kind":       tc.xr.kind,
+				"metadata":   metadata,
+			})}
+			res, err := srv.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			denied := len(res.GetResults()) > 0 && res.GetResults()[0].GetSeverity() == fnapi.Severity_SEVERITY_FATAL
+			if denied != tc.denied {
+				t.Errorf("Expected denied %t but got results %v", tc.denied, res.GetResults())
+			}
+		})
+	}
+}
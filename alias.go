@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+)
+
+// WithAliases registers additional names a ServerFunction can be called
+// with, e.g. to keep Compositions working after it was renamed.
+//
+// An alias must be neither the name nor an alias of another registered
+// ServerFunction. NewServer panics otherwise.
+func WithAliases(aliases ...string) FunctionOption {
+	return func(fn *registeredFunction) {
+		for _, a := range aliases {
+			fn.setAlias(a, "")
+		}
+	}
+}
+
+// WithDeprecatedAlias registers an alias like WithAliases and marks it as
+// deprecated. Calls with the alias succeed but return a warning result with
+// the given message, e.g. the name to migrate to.
+func WithDeprecatedAlias(alias, message string) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.setAlias(alias, message)
+	}
+}
+
+// WithDeprecation marks a ServerFunction as deprecated. All calls succeed but
+// return a warning result with the given message.
+func WithDeprecation(message string) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.deprecation = message
+	}
+}
+
+func (r *registeredFunction) setAlias(alias, deprecation string) {
+	if r.aliases == nil {
+		r.aliases = map[string]string{}
+	}
+	r.aliases[alias] = deprecation
+}
+
+// deprecationOf returns the deprecation message if the ServerFunction is
+// called with the given name. It is empty if the name is not deprecated.
+func (r *registeredFunction) deprecationOf(name string) string {
+	if msg := r.aliases[name]; msg != "" {
+		return msg
+	}
+	return r.deprecation
+}
+
+// validateAliases returns an error if an alias is the name of a registered
+// ServerFunction or registered by more than one ServerFunction.
+func validateAliases(registered map[string]*registeredFunction) error {
+	owners := map[string]string{}
+	for _, name := range sortedKeys(registered) {
+		for _, a := range sortedKeys(registered[name].aliases) {
+			if _, ok := registered[a]; ok {
+				return errors.Errorf("alias %q of server function %q is the name of a server function", a, name)
+			}
+			if owner, ok := owners[a]; ok {
+				return errors.Errorf("alias %q is registered by server functions %q and %q", a, owner, name)
+			}
+			owners[a] = name
+		}
+	}
+	return nil
+}
+
+// registeredAliases returns the aliases of all registered ServerFunctions
+// mapped to their names.
+func registeredAliases(registered map[string]*registeredFunction) map[string]string {
+	aliases := map[string]string{}
+	for name, reg := range registered {
+		for a := range reg.aliases {
+			aliases[a] = name
+		}
+	}
+	return aliases
+}
+
+// deprecationWarning returns the warning result of a call of a deprecated
+// ServerFunction name.
+func deprecationWarning(name, message string) *fnapi.Result {
+	return &fnapi.Result{
+		Severity: fnapi.Severity_SEVERITY_WARNING,
+		Message:  fmt.Sprintf("server function %q is deprecated: %s", name, message),
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestAliases(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	m := NewMetrics()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+	srv := NewServer(
+		WithMetrics(m),
+		WithFunction("current", noop,
+			WithAliases("alias"),
+			WithDeprecatedAlias("old", "use current instead"),
+		),
+		WithFunction("legacy", noop, WithDeprecation("will be removed in v2")),
+	)
+
+	cases := map[string]struct {
+		function string
+		want     []*fnapi.Result
+	}{
+		"Name": {
+			function: "current",
+		},
+		"Alias": {
+			function: "alias",
+		},
+		"DeprecatedAlias": {
+			function: "old",
+			want: []*fnapi.Result{{
+				Severity: fnapi.Severity_SEVERITY_WARNING,
+				Message:  `server function "old" is deprecated: use current instead`,
+			}},
+		},
+		"DeprecatedFunction": {
+			function: "legacy",
+			want: []*fnapi.Result{{
+				Severity: fnapi.Severity_SEVERITY_WARNING,
+				Message:  `server function "legacy" is deprecated: will be removed in v2`,
+			}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := srv.RunFunction(context.Background(), newTestRequest(t, tc.function, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, res.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+
+	want := `
+# HELP function_server_deprecated_invocations_total Total number of server function invocations with a deprecated name.
+# TYPE function_server_deprecated_invocations_total counter
+function_server_deprecated_invocations_total{function="current",name="old"} 1
+function_server_deprecated_invocations_total{function="legacy",name="legacy"} 1
+# HELP function_server_invocations_total Total number of server function invocations.
+# TYPE function_server_invocations_total counter
+function_server_invocations_total{function="current"} 3
+function_server_invocations_total{function="legacy"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "function_server_deprecated_invocations_total", "function_server_invocations_total"); err != nil {
+		t.Error(err)
+	}
+
+	wantInfo := []FunctionInfo{
+		{Name: "current", Aliases: []string{"alias", "old"}},
+		{Name: "legacy", Deprecation: "will be removed in v2"},
+	}
+	if diff := cmp.Diff(wantInfo, srv.Functions()); diff != "" {
+		t.Errorf("Functions: -want +got\n%s", diff)
+	}
+
+	// Config aliases must not collide with registered aliases.
+	err := srv.ApplyConfig(&Config{Functions: map[string]FunctionConfig{"legacy": {Aliases: []string{"old"}}}})
+	if err == nil {
+		t.Error("Expected config with registered alias to be invalid")
+	}
+}
+
+func TestAliasCollisions(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	cases := map[string]struct {
+		opts []ServerOption
+		want string
+	}{
+		"SameAlias": {
+			opts: []ServerOption{
+				WithFunction("b", noop, WithAliases("shared")),
+				WithFunction("a", noop, WithAliases("shared")),
+			},
+			want: `alias "shared" is registered by server functions "a" and "b"`,
+		},
+		"ShadowedName": {
+			opts: []ServerOption{
+				WithFunction("current", noop),
+				WithFunction("other", noop, WithDeprecatedAlias("current", "use other")),
+			},
+			want: `alias "current" of server function "other" is the name of a server function`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tc.opts...)
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("Expected error %q but got %v", tc.want, err)
+			}
+		})
+	}
+}
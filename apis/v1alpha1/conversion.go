@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/mistermx/crossplane-function-server/apis/v1beta1"
+)
+
+// ConvertTo converts this ServerInput to the hub version (v1beta1).
+func (src *ServerInput) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1beta1.ServerInput)
+	if !ok {
+		return errors.Errorf("unsupported conversion target %T", dstRaw)
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.SetGroupVersionKind(v1beta1.ServerInputGroupVersionKind)
+	dst.Spec = v1beta1.ServerInputSpec{
+		FunctionName: src.Spec.FunctionName,
+		Input:        *src.Spec.Input.DeepCopy(),
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1beta1) to this version.
+//
+// It fails if the source uses features that cannot be represented in
+// v1alpha1.
+func (dst *ServerInput) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1beta1.ServerInput)
+	if !ok {
+		return errors.Errorf("unsupported conversion source %T", srcRaw)
+	}
+	if len(src.Spec.Steps) > 0 || src.Spec.FunctionVersion != "" || src.Spec.Timeout != nil {
+		return errors.New("steps, functionVersion and timeout are not supported by v1alpha1")
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.SetGroupVersionKind(ServerInputGroupVersionKind)
+	dst.Spec = ServerInputSpec{
+		FunctionName: src.Spec.FunctionName,
+		Input:        *src.Spec.Input.DeepCopy(),
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package v1alpha1
 import (
 	evtv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // ServerInputSpec defines request data for a ServerFunction call.
@@ -26,21 +27,21 @@ type ServerInput struct {
 
 // +kubebuilder:object:root=true
 
-// // ServerInputList contains a list of ServerInputs
-// type CompositeServerInputList struct {
-// 	metav1.TypeMeta `json:",inline"`
-// 	metav1.ListMeta `json:"metadata,omitempty"`
-// 	Items           []CompositeServerInput `json:"items"`
-// }
-
-// // Repository type metadata.
-// var (
-// 	CompositeServerInputKind             = "ServerInput"
-// 	CompositeServerInputGroupKind        = schema.GroupKind{Group: CRDGroup, Kind: CompositeServerInputKind}.String()
-// 	CompositeServerInputKindAPIVersion   = CompositeServerInputKind + "." + GroupVersion.String()
-// 	CompositeServerInputGroupVersionKind = GroupVersion.WithKind(CompositeServerInputKind)
-// )
-
-// func init() {
-// 	SchemeBuilder.Register(&CompositeServerInput{}, &CompositeServerInputList{})
-// }
+// ServerInputList contains a list of ServerInputs
+type ServerInputList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerInput `json:"items"`
+}
+
+// ServerInput type metadata.
+var (
+	ServerInputKind             = "ServerInput"
+	ServerInputGroupKind        = schema.GroupKind{Group: CRDGroup, Kind: ServerInputKind}.String()
+	ServerInputKindAPIVersion   = ServerInputKind + "." + GroupVersion.String()
+	ServerInputGroupVersionKind = GroupVersion.WithKind(ServerInputKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&ServerInput{}, &ServerInputList{})
+}
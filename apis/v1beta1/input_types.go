@@ -0,0 +1,110 @@
+package v1beta1
+
+import (
+	evtv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ServerInputStep defines a single ServerFunction call.
+type ServerInputStep struct {
+	// FunctionName is the name of the ServerFunction to be invoked.
+	FunctionName string `json:"functionName"`
+
+	// FunctionVersion is the expected version of the ServerFunction.
+	// If set, the call fails if the ServerFunction registered under
+	// FunctionName has a different version.
+	// +optional
+	FunctionVersion string `json:"functionVersion,omitempty"`
+
+	// Timeout after which the ServerFunction call is aborted. The timeout
+	// the ServerFunction is registered with is used if it is zero.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Input is the request payload that should be passed to the function.
+	// It can contain any kind of valid JSON data.
+	// +optional
+	Input evtv1.JSON `json:"input,omitempty"`
+}
+
+// ServerInputSpec defines request data for a ServerFunction call.
+//
+// Either a single ServerFunction is called by setting FunctionName or a
+// sequence of ServerFunctions is called by setting Steps.
+type ServerInputSpec struct {
+	// FunctionName is the name of the ServerFunction to be invoked.
+	// +optional
+	FunctionName string `json:"functionName,omitempty"`
+
+	// FunctionVersion is the expected version of the ServerFunction.
+	// If set, the call fails if the ServerFunction registered under
+	// FunctionName has a different version.
+	// +optional
+	FunctionVersion string `json:"functionVersion,omitempty"`
+
+	// Timeout after which the ServerFunction call is aborted. The timeout
+	// the ServerFunction is registered with is used if it is zero.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Input is the request payload that should be passed to the function.
+	// It can contain any kind of valid JSON data.
+	// +optional
+	Input evtv1.JSON `json:"input,omitempty"`
+
+	// Steps is a list of ServerFunction calls that are executed in order.
+	// Every step sees the desired state and context of the steps before it,
+	// both in its request and its response.
+	// +optional
+	Steps []ServerInputStep `json:"steps,omitempty"`
+}
+
+// GetSteps returns the ServerFunction calls defined by this spec.
+//
+// If Steps is empty, the function call defined by the fields of the spec
+// itself is returned as the only step.
+func (s *ServerInputSpec) GetSteps() []ServerInputStep {
+	if len(s.Steps) > 0 {
+		return s.Steps
+	}
+	return []ServerInputStep{{
+		FunctionName:    s.FunctionName,
+		FunctionVersion: s.FunctionVersion,
+		Timeout:         s.Timeout,
+		Input:           s.Input,
+	}}
+}
+
+// +kubebuilder:object:root=true
+
+// ServerInput defines request data for a ServerFunction call.
+type ServerInput struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ServerInputSpec `json:"spec"`
+}
+
+// Hub marks this type as a conversion hub.
+func (*ServerInput) Hub() {}
+
+// +kubebuilder:object:root=true
+
+// ServerInputList contains a list of ServerInputs
+type ServerInputList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerInput `json:"items"`
+}
+
+// ServerInput type metadata.
+var (
+	ServerInputKind             = "ServerInput"
+	ServerInputGroupKind        = schema.GroupKind{Group: CRDGroup, Kind: ServerInputKind}.String()
+	ServerInputKindAPIVersion   = ServerInputKind + "." + GroupVersion.String()
+	ServerInputGroupVersionKind = GroupVersion.WithKind(ServerInputKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&ServerInput{}, &ServerInputList{})
+}
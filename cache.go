@@ -0,0 +1,179 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultCacheMaxEntries is the default number of responses a response cache
+// holds per ServerFunction.
+const DefaultCacheMaxEntries = 128
+
+// CacheOption configures the response cache of a ServerFunction.
+type CacheOption func(c *responseCache)
+
+// CacheMaxEntries limits the number of responses that are cached. If the
+// limit is reached, the least recently used response is evicted.
+func CacheMaxEntries(n int) CacheOption {
+	return func(c *responseCache) {
+		c.maxEntries = n
+	}
+}
+
+// CacheDependsOnComposed declares the observed composed resources a
+// ServerFunction reads. Their state is part of the cache key.
+func CacheDependsOnComposed(names ...string) CacheOption {
+	return func(c *responseCache) {
+		c.composed = append(c.composed, names...)
+	}
+}
+
+// CacheDependsOnContext declares the context keys a ServerFunction reads.
+// Their values are part of the cache key.
+func CacheDependsOnContext(keys ...string) CacheOption {
+	return func(c *responseCache) {
+		c.contextKeys = append(c.contextKeys, keys...)
+	}
+}
+
+// WithCache caches the responses of a ServerFunction for the given TTL.
+//
+// Responses are cached by the input of the ServerFunction, the observed
+// composite resource, the desired state of previous steps and pipeline
+// functions and the declared dependencies (see CacheDependsOnComposed and CacheDependsOnContext).
+// Only use it for ServerFunctions that do not depend on any other state.
+func WithCache(ttl time.Duration, opts ...CacheOption) FunctionOption {
+	return func(fn *registeredFunction) {
+		c := &responseCache{
+			ttl:        ttl,
+			maxEntries: DefaultCacheMaxEntries,
+			entries:    map[string]*list.Element{},
+			lru:        list.New(),
+			now:        time.Now,
+		}
+		for _, o := range opts {
+			o(c)
+		}
+		fn.cache = c
+	}
+}
+
+type cacheEntry struct {
+	key     string
+	res     *RunServerFunctionResponse
+	expires time.Time
+}
+
+// responseCache is a size bound LRU cache of ServerFunction responses.
+type responseCache struct {
+	ttl         time.Duration
+	maxEntries  int
+	composed    []string
+	contextKeys []string
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+// key returns the cache key of a ServerFunction call.
+func (c *responseCache) key(name string, input []byte, req *fnapi.RunFunctionRequest, res *RunServerFunctionResponse) string {
+	h := sha256.New()
+	write := func(b []byte) {
+		// Prefix every part with its length to avoid ambiguous keys.
+		n := len(b)
+		_, _ = h.Write([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+		_, _ = h.Write(b)
+	}
+	writeProto := func(m proto.Message) {
+		b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+		write(b)
+	}
+
+	write([]byte(name))
+	write(input)
+	writeProto(req.GetObserved().GetComposite())
+	// Functions like ExecFunction read the desired state of previous
+	// functions of the pipeline.
+	writeProto(req.GetDesired())
+	for _, composed := range c.composed {
+		write([]byte(composed))
+		writeProto(req.GetObserved().GetResources()[composed])
+	}
+	for _, key := range c.contextKeys {
+		write([]byte(key))
+		writeProto(req.GetContext().GetFields()[key])
+	}
+
+	writeProto(res.DesiredComposite)
+	desired := make([]string, 0, len(res.DesiredComposed))
+	for composed := range res.DesiredComposed {
+		desired = append(desired, composed)
+	}
+	sort.Strings(desired)
+	for _, composed := range desired {
+		write([]byte(composed))
+		writeProto(res.DesiredComposed[composed])
+	}
+	writeProto(res.DesiredContext)
+	for _, r := range res.Results {
+		writeProto(r)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns a copy of the cached response for key if it exists and is not
+// expired.
+func (c *responseCache) get(key string) (*RunServerFunctionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if c.now().After(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.res.DeepCopy(), true
+}
+
+// set caches a copy of res for key.
+func (c *responseCache) set(key string, res *RunServerFunctionResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &cacheEntry{
+		key:     key,
+		res:     res.DeepCopy(),
+		expires: c.now().Add(c.ttl),
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// len returns the number of cached responses.
+func (c *responseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
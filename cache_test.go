@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+)
+
+func TestRunFunctionCache(t *testing.T) {
+	calls := 0
+	countFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		calls++
+		return res.SetContextField("calls", calls)
+	})
+	srv := NewServer(WithFunction("count", countFunction,
+		WithCache(time.Minute, CacheMaxEntries(2), CacheDependsOnComposed("db"), CacheDependsOnContext("env")),
+	))
+	cache := srv.functions["count"].cache
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	run := func(req *fnapi.RunFunctionRequest) float64 {
+		t.Helper()
+		res, err := srv.RunFunction(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.GetContext().GetFields()["calls"].GetNumberValue()
+	}
+	withInput := func(name string) *fnapi.RunFunctionRequest {
+		return newTestRequest(t, "count", map[string]any{"name": name})
+	}
+
+	withDesired := func(name string) *fnapi.RunFunctionRequest {
+		req := withInput("a")
+		req.Desired.Resources = map[string]*fnapi.Resource{
+			name: {Resource: mustStruct(t, map[string]any{"kind": "Bucket"})},
+		}
+		return req
+	}
+
+	type want struct {
+		calls float64
+		len   int
+	}
+	cases := []struct {
+		name  string
+		setup func()
+		req   *fnapi.RunFunctionRequest
+		want  want
+	}{
+		{
+			name: "Miss",
+			req:  withInput("a"),
+			want: want{calls: 1, len: 1},
+		},
+		{
+			name: "Hit",
+			req:  withInput("a"),
+			want: want{calls: 1, len: 1},
+		},
+		{
+			name: "UndeclaredComposedResourceIgnored",
+			req: func() *fnapi.RunFunctionRequest {
+				req := withInput("a")
+				req.Observed.Resources = map[string]*fnapi.Resource{
+					"bucket": {Resource: mustStruct(t, map[string]any{"kind": "Bucket"})},
+				}
+				return req
+			}(),
+			want: want{calls: 1, len: 1},
+		},
+		{
+			name: "DeclaredComposedResourceChanged",
+			req: func() *fnapi.RunFunctionRequest {
+				req := withInput("a")
+				req.Observed.Resources = map[string]*fnapi.Resource{
+					"db": {Resource: mustStruct(t, map[string]any{"kind": "Database"})},
+				}
+				return req
+			}(),
+			want: want{calls: 2, len: 2},
+		},
+		{
+			name: "DeclaredContextChanged",
+			req: func() *fnapi.RunFunctionRequest {
+				req := withInput("a")
+				req.Context = mustStruct(t, map[string]any{"env": "prod"})
+				return req
+			}(),
+			want: want{calls: 3, len: 2},
+		},
+		{
+			name: "LeastRecentlyUsedEvicted",
+			req:  withInput("a"),
+			want: want{calls: 4, len: 2},
+		},
+		{
+			name:  "Expired",
+			setup: func() { now = now.Add(2 * time.Minute) },
+			req:   withInput("a"),
+			want:  want{calls: 5, len: 2},
+		},
+		{
+			name: "DesiredStateChanged",
+			req:  withDesired("bucket"),
+			want: want{calls: 6, len: 2},
+		},
+		{
+			name: "DesiredStateUnchanged",
+			req:  withDesired("bucket"),
+			want: want{calls: 6, len: 2},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.setup != nil {
+				tc.setup()
+			}
+			if got := run(tc.req); got != tc.want.calls {
+				t.Errorf("Expected response of call %v but got %v", tc.want.calls, got)
+			}
+			if got := cache.len(); got != tc.want.len {
+				t.Errorf("Expected %d cached responses but got %d", tc.want.len, got)
+			}
+		})
+	}
+}
+
+func TestRunFunctionCacheErrorNotCached(t *testing.T) {
+	calls := 0
+	failFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		calls++
+		return errors.New("boom")
+	})
+	srv := NewServer(WithFunction("fail", failFunction, WithCache(time.Minute)))
+	for i := 0; i < 2; i++ {
+		_, _ = srv.RunFunction(context.Background(), newTestRequest(t, "fail", nil))
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls but got %d", calls)
+	}
+	if got := srv.functions["fail"].cache.len(); got != 0 {
+		t.Errorf("Expected no cached responses but got %d", got)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// CELInput is the input of a CELFunction.
+type CELInput struct {
+	// Patches are applied in order. Later patches see the changes of earlier
+	// ones in desired.
+	Patches []CELPatch `json:"patches"`
+}
+
+// A CELPatch sets a field of a desired resource to the result of a CEL
+// expression.
+type CELPatch struct {
+	// Resource is the name of the desired composed resource to patch. The
+	// desired composite resource is patched if empty.
+	Resource string `json:"resource,omitempty"`
+
+	// FieldPath is the path of the field to set, e.g.
+	// spec.forProvider.tags[team].
+	FieldPath string `json:"fieldPath"`
+
+	// Expression is the CEL expression that computes the value of the field.
+	Expression string `json:"expression"`
+}
+
+// A CELFunction is a ServerFunction that sets fields of desired resources to
+// the results of the CEL expressions of its input.
+//
+// Expressions can read the following variables:
+//
+//	observed  # {"composite": {...}, "resources": {name: {...}}}
+//	desired   # The desired state, like observed.
+//	context   # The context of the pipeline.
+//
+// Numbers of resources are doubles, like in JSON. The string and encoder
+// extensions of CEL are available.
+type CELFunction struct {
+	env *cel.Env
+}
+
+// NewCELFunction returns a new CELFunction.
+func NewCELFunction() (*CELFunction, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("observed", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("desired", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("context", cel.MapType(cel.StringType, cel.DynType)),
+		ext.Strings(),
+		ext.Encoders(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create CEL environment")
+	}
+	return &CELFunction{env: env}, nil
+}
+
+// NewInput implements InputFactory.
+func (f *CELFunction) NewInput() any {
+	return &CELInput{}
+}
+
+// RawInput implements RawInputFunction, since its input contains CEL expressions.
+func (f *CELFunction) RawInput() bool {
+	return true
+}
+
+// ValidateInput implements InputValidator.
+func (f *CELFunction) ValidateInput(input any) field.ErrorList {
+	in := input.(*CELInput) //nolint:forcetypeassert // NewInput returns a *CELInput.
+	var errs field.ErrorList
+	for i, p := range in.Patches {
+		path := field.NewPath("patches").Index(i)
+		if _, err := fieldpath.Parse(p.FieldPath); err != nil || p.FieldPath == "" {
+			errs = append(errs, field.Invalid(path.Child("fieldPath"), p.FieldPath, "must be a valid field path"))
+		}
+		if _, iss := f.env.Compile(p.Expression); iss.Err() != nil {
+			errs = append(errs, field.Invalid(path.Child("expression"), p.Expression, iss.Err().Error()))
+		}
+	}
+	return errs
+}
+
+// Run implements ServerFunction.
+//
+// Patches are applied to the desired resources in place, so their readiness
+// and connection details are kept. All desired resources are written to res,
+// including those that are not patched.
+func (f *CELFunction) Run(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	in := &CELInput{}
+	if err := req.GetInput(in); err != nil {
+		return errors.Wrap(err, "cannot decode input")
+	}
+	native := req.GetNativeRequest()
+	desired, fnCtx := native.GetDesired(), native.GetContext()
+	if r, ok := res.(*RunServerFunctionResponse); ok {
+		desired, fnCtx = r.desiredState(native)
+	}
+	state := &fnapi.State{}
+	if desired != nil {
+		state = proto.Clone(desired).(*fnapi.State) //nolint:forcetypeassert // Clone returns the same type.
+	}
+
+	composite := state.GetComposite().GetResource().AsMap()
+	resources := make(map[string]any, len(state.GetResources()))
+	for name, r := range state.GetResources() {
+		resources[name] = r.GetResource().AsMap()
+	}
+	vars := map[string]any{
+		"observed": map[string]any{
+			"composite": native.GetObserved().GetComposite().GetResource().AsMap(),
+			"resources": resourceMaps(native.GetObserved().GetResources()),
+		},
+		"desired": map[string]any{"composite": composite, "resources": resources},
+		"context": fnCtx.AsMap(),
+	}
+
+	patched := map[string]bool{}
+	for i, p := range in.Patches {
+		v, err := evalCEL(f.env, p.Expression, vars)
+		if err != nil {
+			return errors.Wrapf(err, "cannot evaluate expression of patch %d", i)
+		}
+		target := composite
+		if p.Resource != "" {
+			r, ok := resources[p.Resource]
+			if !ok {
+				return errors.Errorf("patch %d: desired resource %q does not exist", i, p.Resource)
+			}
+			target = r.(map[string]any) //nolint:forcetypeassert // Always a map, see above.
+		}
+		if err := fieldpath.Pave(target).SetValue(p.FieldPath, v); err != nil {
+			return errors.Wrapf(err, "cannot set field path %q of patch %d", p.FieldPath, i)
+		}
+		patched[p.Resource] = true
+	}
+
+	if patched[""] {
+		s, err := structpb.NewStruct(composite)
+		if err != nil {
+			return errors.Wrap(err, "cannot convert composite resource to protobuf")
+		}
+		if state.Composite == nil {
+			state.Composite = &fnapi.Resource{}
+		}
+		state.Composite.Resource = s
+		res.SetCompositeRaw(state.Composite)
+	}
+	for name, r := range state.GetResources() {
+		if patched[name] {
+			s, err := structpb.NewStruct(resources[name].(map[string]any)) //nolint:forcetypeassert // Always a map, see above.
+			if err != nil {
+				return errors.Wrapf(err, "cannot convert composed resource %q to protobuf", name)
+			}
+			r.Resource = s
+		}
+		res.SetComposedRaw(name, r)
+	}
+	return nil
+}
+
+// evalCEL evaluates a CEL expression and returns its result as JSON value.
+func evalCEL(env *cel.Env, expr string, vars map[string]any) (any, error) {
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	val, _, err := prg.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	v, err := val.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot convert %s to JSON", val.Type())
+	}
+	return v.(*structpb.Value).AsInterface(), nil //nolint:forcetypeassert // Converted to *structpb.Value.
+}
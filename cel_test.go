@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestCELFunction(t *testing.T) {
+	fn, err := NewCELFunction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(WithFunction("cel", fn))
+
+	request := func(t *testing.T, input map[string]any) *fnapi.RunFunctionRequest {
+		t.Helper()
+		req := newTestRequest(t, "cel", input)
+		req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+			"apiVersion": "example.org/v1",
+			"kind":       "XR",
+			"metadata":   map[string]any{"name": "example"},
+			"spec":       map[string]any{"replicas": 3},
+		})}
+		req.Observed.Resources = map[string]*fnapi.Resource{
+			"bucket": {Resource: mustStruct(t, map[string]any{"status": map[string]any{"atProvider": map[string]any{"arn": "arn:aws:s3:::example"}}})},
+		}
+		req.Desired = &fnapi.State{Resources: map[string]*fnapi.Resource{
+			"bucket": {Resource: mustStruct(t, map[string]any{"kind": "Bucket"}), Ready: fnapi.Ready_READY_TRUE},
+			"queue":  {Resource: mustStruct(t, map[string]any{"kind": "Queue"}), ConnectionDetails: map[string][]byte{"url": []byte("https://example.org")}},
+		}}
+		return req
+	}
+
+	cases := map[string]struct {
+		input map[string]any
+		want  *fnapi.RunFunctionResponse
+		err   bool
+	}{
+		"Patches": {
+			input: map[string]any{"patches": []any{
+				map[string]any{"resource": "bucket", "fieldPath": "metadata.name", "expression": `observed.composite.metadata.name + "-bucket"`},
+				map[string]any{"resource": "bucket", "fieldPath": "spec.forProvider.tags[owner]", "expression": `desired.resources.bucket.metadata.name.upperAscii()`},
+				map[string]any{"fieldPath": "status.arn", "expression": `observed.resources.bucket.status.atProvider.arn`},
+				map[string]any{"fieldPath": "status.capacity", "expression": `observed.composite.spec.replicas * 2.0`},
+			}},
+			want: &fnapi.RunFunctionResponse{
+				Desired: &fnapi.State{
+					Composite: &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+						"status": map[string]any{"arn": "arn:aws:s3:::example", "capacity": 6},
+					})},
+					Resources: map[string]*fnapi.Resource{
+						"bucket": {Resource: mustStruct(t, map[string]any{
+							"kind":     "Bucket",
+							"metadata": map[string]any{"name": "example-bucket"},
+							"spec":     map[string]any{"forProvider": map[string]any{"tags": map[string]any{"owner": "EXAMPLE-BUCKET"}}},
+						}), Ready: fnapi.Ready_READY_TRUE},
+						"queue": {Resource: mustStruct(t, map[string]any{"kind": "Queue"}), ConnectionDetails: map[string][]byte{"url": []byte("https://example.org")}},
+					},
+				},
+			},
+		},
+		"InvalidExpression": {
+			input: map[string]any{"patches": []any{
+				map[string]any{"fieldPath": "status.arn", "expression": `observed.`},
+			}},
+			want: &fnapi.RunFunctionResponse{
+				Desired: &fnapi.State{Resources: map[string]*fnapi.Resource{
+					"bucket": {Resource: mustStruct(t, map[string]any{"kind": "Bucket"}), Ready: fnapi.Ready_READY_TRUE},
+					"queue":  {Resource: mustStruct(t, map[string]any{"kind": "Queue"}), ConnectionDetails: map[string][]byte{"url": []byte("https://example.org")}},
+				}},
+				Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_FATAL, Message: "cannot prepare input of subroutine function \"cel\": invalid input: patches[0].expression: Invalid value: \"observed.\": ERROR: <input>:1:10: Syntax error: no viable alternative at input '.'\n | observed.\n | .........^"}},
+			},
+		},
+		"MissingResource": {
+			input: map[string]any{"patches": []any{
+				map[string]any{"resource": "database", "fieldPath": "metadata.name", "expression": `"db"`},
+			}},
+			err: true,
+		},
+		"NoSuchKey": {
+			input: map[string]any{"patches": []any{
+				map[string]any{"fieldPath": "status.arn", "expression": `observed.resources.database.status`},
+			}},
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := srv.RunFunction(context.Background(), request(t, tc.input))
+			if tc.err != (err != nil) {
+				t.Fatalf("Expected error %t but got %v", tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			if diff := cmp.Diff(tc.want, res, protocmp.Transform(), protocmp.IgnoreFields(&fnapi.RunFunctionResponse{}, "meta")); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Package cli provides a reusable command line interface for function server
+// binaries.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/crossplane/function-sdk-go"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	server "github.com/mistermx/crossplane-function-server"
+	"github.com/mistermx/crossplane-function-server/apis/v1alpha1"
+	"github.com/mistermx/crossplane-function-server/apis/v1beta1"
+)
+
+// CLI of a function server binary.
+type CLI struct {
+	ServerFlags
+
+	Serve         ServeCmd             `cmd:"" default:"withargs" help:"Serve the server functions."`
+	Render        server.RenderCommand `cmd:"" help:"Render a Composition offline using the server functions of this binary."`
+	ListFunctions ListFunctionsCmd     `cmd:"" help:"List all server functions."`
+	Schema        SchemaCmd            `cmd:"" help:"Print the OpenAPI schema of the input of a server function."`
+	Replay        ReplayCmd            `cmd:"" help:"Replay recorded requests and diff the responses against the recorded ones."`
+}
+
+// Main runs the CLI of a function server binary that serves a Server
+// configured by opts. It exits the process if the command fails.
+//
+// Usually opts register ServerFunctions using server.WithFunction:
+//
+//	func main() {
+//		cli.Main(
+//			server.WithFunction("my-function", &MyFunction{}),
+//		)
+//	}
+func Main(opts ...server.ServerOption) {
+	parser, cli, err := newParser(os.Stdout, opts...)
+	if err != nil {
+		// Report the error like kong.Kong.FatalIfErrorf.
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", filepath.Base(os.Args[0]), err)
+		os.Exit(1)
+	}
+	ctx, err := parser.Parse(os.Args[1:])
+	parser.FatalIfErrorf(err)
+	err = ctx.Run()
+	cli.close()
+	parser.FatalIfErrorf(err)
+}
+
+// newParser returns a parser for CLI that writes command output to out.
+// Commands get the Server from ServerFlags.newServer.
+func newParser(out io.Writer, opts ...server.ServerOption) (*kong.Kong, *CLI, error) {
+	if err := v1alpha1.AddToScheme(composed.Scheme); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot add function server v1alpha1 API to scheme")
+	}
+	if err := v1beta1.AddToScheme(composed.Scheme); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot add function server v1beta1 API to scheme")
+	}
+	cli := &CLI{}
+	parser, err := kong.New(cli,
+		kong.Description("A Crossplane function server."),
+		kong.Bind(opts, &cli.ServerFlags),
+		kong.BindToProvider(func() (*server.Server, error) {
+			return cli.newServer(opts)
+		}),
+		kong.BindTo(out, (*io.Writer)(nil)),
+	)
+	return parser, cli, err
+}
+
+// ServerFlags configure the server functions of all commands.
+type ServerFlags struct {
+	Plugins string `help:"Manifest of WebAssembly plugins to register as server functions." type:"existingfile" env:"SERVER_PLUGINS"`
+	Config  string `help:"YAML file to configure the server functions." type:"existingfile" env:"SERVER_CONFIG"`
+
+	// closers release the plugins loaded by newServer.
+	closers []func(context.Context) error
+}
+
+// newServer returns a Server with the given srvOpts that serves the
+// ServerFunctions of opts and the plugins, configured by the config.
+func (f *ServerFlags) newServer(opts []server.ServerOption, srvOpts ...server.ServerOption) (*server.Server, error) {
+	all := append(append([]server.ServerOption{}, srvOpts...), opts...)
+	if f.Plugins != "" {
+		plugins, closePlugins, err := server.LoadPlugins(context.Background(), f.Plugins)
+		if err != nil {
+			return nil, err
+		}
+		f.closers = append(f.closers, closePlugins)
+		all = append(all, plugins...)
+	}
+	srv, err := server.New(all...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create server")
+	}
+	if f.Config != "" {
+		cfg, err := server.LoadConfig(f.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err := srv.ApplyConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return srv, nil
+}
+
+// close releases the plugins loaded by newServer.
+func (f *ServerFlags) close() {
+	for _, c := range f.closers {
+		_ = c(context.Background())
+	}
+	f.closers = nil
+}
+
+// ServeCmd serves the server functions via gRPC.
+//
+// Besides the FunctionRunnerService, the grpc.health.v1 health service is
+// served. See server.Serve.
+type ServeCmd struct {
+	Debug bool `short:"d" help:"Emit debug logs in addition to info logs."`
+
+	Network     string `help:"Network on which to listen for gRPC connections." default:"tcp"`
+	Address     string `help:"Address at which to listen for gRPC connections." default:":9443"`
+	TLSCertsDir string `help:"Directory containing server certs (tls.key, tls.crt) and the CA used to verify client certificates (ca.crt)" env:"TLS_SERVER_CERTS_DIR"`
+	Insecure    bool   `help:"Run without mTLS credentials. If you supply this flag --tls-server-certs-dir will be ignored."`
+
+	ConfigReloadInterval time.Duration `help:"Interval in which the config file is checked for changes. The config is not reloaded if zero." default:"0"`
+
+	Reflection          bool          `help:"Enable gRPC server reflection."`
+	HealthCheckInterval time.Duration `help:"Interval in which the readiness of server functions is checked." default:"10s"`
+
+	MetricsAddress string `help:"Address at which to expose Prometheus metrics. Metrics are not exposed if empty." default:":8080"`
+	Tracing        bool   `help:"Export traces via OTLP. The exporter is configured by the standard OTEL_EXPORTER_OTLP_* environment variables."`
+	RecordDir      string `help:"Directory to record all requests and responses to, with secrets redacted. Requests are not recorded if empty." env:"RECORD_DIR"`
+
+	DebugGateway        bool   `help:"Serve an HTTP/JSON gateway to call server functions for debugging."`
+	DebugGatewayAddress string `help:"Address at which to serve the debug gateway." default:"localhost:8081"`
+}
+
+// Validate the flags.
+func (c *ServeCmd) Validate() error {
+	if c.HealthCheckInterval <= 0 {
+		return errors.Errorf("--health-check-interval must be positive, got %s", c.HealthCheckInterval)
+	}
+	return nil
+}
+
+// Run the server.
+func (c *ServeCmd) Run(opts []server.ServerOption, flags *ServerFlags) error {
+	log, err := function.NewLogger(c.Debug)
+	if err != nil {
+		return err
+	}
+
+	metrics := server.NewMetrics()
+	errs := make(chan error, 3)
+	if c.MetricsAddress != "" {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(metrics)
+		go func() {
+			errs <- errors.Wrap(server.ServeMetrics(c.MetricsAddress, registry), "cannot serve metrics")
+		}()
+	}
+
+	srvOpts := []server.ServerOption{
+		server.WithLogger(log),
+		server.WithMetrics(metrics),
+	}
+	if c.Tracing {
+		tp, err := server.NewOTLPTracerProvider(context.Background())
+		if err != nil {
+			return err
+		}
+		defer tp.Shutdown(context.Background()) //nolint:errcheck // Nothing to do if flushing fails on exit.
+		srvOpts = append(srvOpts, server.WithTracerProvider(tp))
+	}
+	if c.RecordDir != "" {
+		srvOpts = append(srvOpts, server.WithRecording(c.RecordDir))
+	}
+
+	srv, err := flags.newServer(opts, srvOpts...)
+	if err != nil {
+		return err
+	}
+	if flags.Config != "" && c.ConfigReloadInterval > 0 {
+		go srv.WatchConfig(context.Background(), flags.Config, c.ConfigReloadInterval)
+	}
+	if c.DebugGateway {
+		log.Info("Serving debug gateway", "address", c.DebugGatewayAddress)
+		go func() {
+			errs <- server.ServeDebug(c.DebugGatewayAddress, srv)
+		}()
+	}
+
+	go func() {
+		errs <- server.Serve(srv,
+			server.SDKOptions(
+				function.Listen(c.Network, c.Address),
+				function.MTLSCertificates(c.TLSCertsDir),
+				function.Insecure(c.Insecure),
+			),
+			server.Reflection(c.Reflection),
+			server.HealthCheckInterval(c.HealthCheckInterval),
+		)
+	}()
+	return <-errs
+}
+
+// ListFunctionsCmd lists all server functions.
+type ListFunctionsCmd struct{}
+
+// Run the command.
+func (c *ListFunctionsCmd) Run(srv *server.Server, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tTIMEOUT\tALIASES")
+	for _, fn := range srv.Functions() {
+		timeout := "-"
+		if fn.Timeout > 0 {
+			timeout = fn.Timeout.String()
+		}
+		version := "-"
+		if fn.Version != "" {
+			version = fn.Version
+		}
+		aliases := "-"
+		if len(fn.Aliases) > 0 {
+			aliases = strings.Join(fn.Aliases, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", fn.Name, version, timeout, aliases)
+	}
+	return errors.Wrap(w.Flush(), "cannot write output")
+}
+
+// SchemaCmd prints the OpenAPI schema of the input of a server function.
+type SchemaCmd struct {
+	Function string `arg:"" help:"Name of the server function."`
+	Output   string `short:"o" enum:"yaml,json" default:"yaml" help:"Output format (yaml, json)."`
+}
+
+// Run the command.
+func (c *SchemaCmd) Run(srv *server.Server, out io.Writer) error {
+	schema, err := srv.InputSchema(c.Function)
+	if err != nil {
+		return errors.Wrap(err, "cannot get input schema")
+	}
+	var raw []byte
+	switch c.Output {
+	case "json":
+		raw, err = json.MarshalIndent(schema, "", "  ")
+		raw = append(raw, '\n')
+	default:
+		raw, err = sigsyaml.Marshal(schema)
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal input schema")
+	}
+	_, err = out.Write(raw)
+	return errors.Wrap(err, "cannot write output")
+}
+
+// ReplayCmd replays recorded requests.
+type ReplayCmd struct {
+	Paths []string `arg:"" type:"path" help:"Recording files or directories containing recordings."`
+}
+
+// Run the command.
+func (c *ReplayCmd) Run(srv *server.Server, out io.Writer) error {
+	failed := 0
+	for _, path := range c.Paths {
+		recs, err := server.LoadRecordings(path)
+		if err != nil {
+			return err
+		}
+		for _, rec := range recs {
+			res := server.Replay(context.Background(), srv, rec)
+			if res.Diff == "" {
+				fmt.Fprintf(out, "%s: OK\n", rec.Time.Format(time.RFC3339Nano))
+				continue
+			}
+			failed++
+			fmt.Fprintf(out, "%s: DIFF (-recorded +replayed)\n%s\n", rec.Time.Format(time.RFC3339Nano), res.Diff)
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d replayed requests differ from their recording", failed)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	server "github.com/mistermx/crossplane-function-server"
+)
+
+type exampleInput struct {
+	Name     string            `json:"name"`
+	Replicas *int              `json:"replicas,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+type exampleFunction struct{}
+
+func (f *exampleFunction) Run(_ context.Context, _ server.ServerFunctionRequest, _ server.ServerFunctionResponse) error {
+	return nil
+}
+
+func (f *exampleFunction) NewInput() any {
+	return &exampleInput{}
+}
+
+func TestCLI(t *testing.T) {
+	opts := []server.ServerOption{
+		server.WithFunction("example", &exampleFunction{}, server.WithVersion("v2"), server.WithTimeout(time.Second), server.WithAliases("old-example")),
+		server.WithFunction("other", &exampleFunction{}),
+	}
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte(`
+functions:
+  other:
+    aliases: [renamed]
+    timeout: 5s
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		out string
+		err bool
+	}
+	cases := map[string]struct {
+		args []string
+		want want
+	}{
+		"ListFunctions": {
+			args: []string{"list-functions"},
+			want: want{
+				out: "NAME     VERSION  TIMEOUT  ALIASES\n" +
+					"example  v2       1s       old-example\n" +
+					"other    -        -        -\n",
+			},
+		},
+		"ListFunctionsConfig": {
+			args: []string{"list-functions", "--config", config},
+			want: want{
+				out: "NAME     VERSION  TIMEOUT  ALIASES\n" +
+					"example  v2       1s       old-example\n" +
+					"other    -        5s       renamed\n",
+			},
+		},
+		"SchemaYAML": {
+			args: []string{"schema", "example"},
+			want: want{
+				out: `properties:
+  labels:
+    additionalProperties:
+      type: string
+    type: object
+  name:
+    type: string
+  replicas:
+    type: integer
+required:
+- name
+type: object
+`,
+			},
+		},
+		"SchemaJSON": {
+			args: []string{"schema", "other", "-o", "json"},
+			want: want{
+				out: `{
+  "type": "object",
+  "required": [
+    "name"
+  ],
+  "properties": {
+    "labels": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "string"
+      }
+    },
+    "name": {
+      "type": "string"
+    },
+    "replicas": {
+      "type": "integer"
+    }
+  }
+}
+`,
+			},
+		},
+		"SchemaUnknownFunction": {
+			args: []string{"schema", "missing"},
+			want: want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			parser, _, err := newParser(out, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, err := parser.Parse(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ctx.Run()
+			if tc.want.err != (err != nil) {
+				t.Fatalf("Expected error %t but got %v", tc.want.err, err)
+			}
+			if diff := cmp.Diff(tc.want.out, out.String()); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServeValidate(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		err  bool
+	}{
+		"DefaultInterval": {
+			args: []string{"serve", "--insecure"},
+		},
+		"ZeroInterval": {
+			args: []string{"serve", "--insecure", "--health-check-interval=0"},
+			err:  true,
+		},
+		"NegativeInterval": {
+			args: []string{"serve", "--insecure", "--health-check-interval=-1s"},
+			err:  true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			parser, _, err := newParser(&bytes.Buffer{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = parser.Parse(tc.args)
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error %t but got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Chain returns a ServerFunction that runs fns in order on the same request
+// and response, like the steps of a server input. It stops at the first
+// error.
+func Chain(fns ...ServerFunction) ServerFunction {
+	return chainFunction(fns)
+}
+
+type chainFunction []ServerFunction
+
+func (c chainFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	for i, fn := range c {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn.Run(ctx, req, res); err != nil {
+			return errors.Wrapf(err, "function %d of chain failed", i)
+		}
+	}
+	return nil
+}
+
+// A Predicate decides whether the ServerFunction of When runs. res contains
+// the changes of previous functions.
+type Predicate func(req ServerFunctionRequest, res ServerFunctionResponse) bool
+
+// When returns a ServerFunction that runs fn only if predicate is true.
+func When(predicate Predicate, fn ServerFunction) ServerFunction {
+	return &whenFunction{predicate: predicate, fn: fn}
+}
+
+type whenFunction struct {
+	predicate Predicate
+	fn        ServerFunction
+}
+
+func (w *whenFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	if !w.predicate(req, res) {
+		return nil
+	}
+	return w.fn.Run(ctx, req, res)
+}
+
+// ForEach returns a ServerFunction that runs fn once for every item of the
+// list at listPath of its input, e.g. spec.buckets. The item is the input of
+// fn and is defaulted and validated if fn implements InputDefaulter or
+// InputValidator. Nothing is run if the list does not exist.
+func ForEach(listPath string, fn ServerFunction) ServerFunction {
+	return &forEachFunction{listPath: listPath, fn: fn}
+}
+
+type forEachFunction struct {
+	listPath string
+	fn       ServerFunction
+}
+
+func (f *forEachFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	input := map[string]any{}
+	if err := req.GetInput(&input); err != nil {
+		return errors.Wrap(err, "cannot decode input")
+	}
+	v, err := fieldpath.Pave(input).GetValue(f.listPath)
+	if fieldpath.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get list %q of input", f.listPath)
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return errors.Errorf("input field %q is not a list", f.listPath)
+	}
+	for i, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal item %d", i)
+		}
+		if raw, err = prepareInput(f.fn, raw); err != nil {
+			return errors.Wrapf(err, "cannot prepare input of item %d", i)
+		}
+		if err := f.fn.Run(ctx, &itemRequest{ServerFunctionRequest: req, input: raw}, res); err != nil {
+			return errors.Wrapf(err, "cannot run function for item %d", i)
+		}
+	}
+	return nil
+}
+
+// itemRequest replaces the input of a request with an item of ForEach.
+type itemRequest struct {
+	ServerFunctionRequest
+	input []byte
+}
+
+func (r *itemRequest) GetInput(target any) error {
+	return json.Unmarshal(r.input, target)
+}
+
+type errConflict struct {
+	field         string
+	first, second int
+}
+
+func (e errConflict) Error() string {
+	return fmt.Sprintf("parallel functions %d and %d set %s differently", e.first, e.second, e.field)
+}
+
+// IsErrorConflict returns true if functions run by Parallel set the same
+// resource or context field to different values.
+func IsErrorConflict(err error) bool {
+	return errors.As(err, &errConflict{})
+}
+
+// Parallel returns a ServerFunction that runs fns concurrently, each on its
+// own copy of the response. The changes of all functions are merged into the
+// response. Functions may set the same resource or context field only to the
+// same value, otherwise Parallel fails with an error that can be checked with
+// IsErrorConflict. Removed composed resources are not merged.
+//
+// If any function fails, the others are canceled and the response is left
+// unchanged.
+func Parallel(fns ...ServerFunction) ServerFunction {
+	return parallelFunction(fns)
+}
+
+type parallelFunction []ServerFunction
+
+func (p parallelFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	base := &RunServerFunctionResponse{}
+	if r, ok := res.(*RunServerFunctionResponse); ok {
+		base = r.DeepCopy()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	branches := make([]*RunServerFunctionResponse, len(p))
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, fn := range p {
+		branches[i] = base.DeepCopy()
+		wg.Add(1)
+		go func(i int, fn ServerFunction) {
+			defer wg.Done()
+			if err := fn.Run(ctx, req, branches[i]); err != nil {
+				once.Do(func() {
+					firstErr = errors.Wrapf(err, "parallel function %d failed", i)
+					cancel()
+				})
+			}
+		}(i, fn)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	changes := make([]responseChanges, len(branches))
+	for i, b := range branches {
+		changes[i] = diffResponse(base, b)
+	}
+	if err := checkConflicts(changes); err != nil {
+		return err
+	}
+	results := base.Results
+	for _, c := range changes {
+		if err := c.apply(res); err != nil {
+			return err
+		}
+		results = append(results, c.results...)
+	}
+	if len(results) > len(base.Results) {
+		res.SetNativeResults(results)
+	}
+	return nil
+}
+
+// responseChanges are the changes a ServerFunction made to a response.
+type responseChanges struct {
+	composite *fnapi.Resource
+	composed  map[string]*fnapi.Resource
+	context   map[string]*structpb.Value
+	results   []*fnapi.Result
+}
+
+// diffResponse returns the changes of r compared to base.
+func diffResponse(base, r *RunServerFunctionResponse) responseChanges {
+	c := responseChanges{composed: map[string]*fnapi.Resource{}, context: map[string]*structpb.Value{}}
+	if r.DesiredComposite != nil && !proto.Equal(r.DesiredComposite, base.DesiredComposite) {
+		c.composite = r.DesiredComposite
+	}
+	for name, res := range r.DesiredComposed {
+		if !proto.Equal(res, base.DesiredComposed[name]) {
+			c.composed[name] = res
+		}
+	}
+	for key, v := range r.DesiredContext.GetFields() {
+		if !proto.Equal(v, base.DesiredContext.GetFields()[key]) {
+			c.context[key] = v
+		}
+	}
+	c.results = r.Results
+	if len(r.Results) >= len(base.Results) && resultsEqual(r.Results[:len(base.Results)], base.Results) {
+		c.results = r.Results[len(base.Results):]
+	}
+	return c
+}
+
+// checkConflicts returns an error if two changes set the same resource or
+// context field to different values.
+func checkConflicts(changes []responseChanges) error {
+	type write struct {
+		index int
+		value proto.Message
+	}
+	writes := map[string]write{}
+	check := func(field string, i int, v proto.Message) error {
+		if w, ok := writes[field]; ok && !proto.Equal(w.value, v) {
+			return errConflict{field: field, first: w.index, second: i}
+		}
+		writes[field] = write{index: i, value: v}
+		return nil
+	}
+	for i, c := range changes {
+		if c.composite != nil {
+			if err := check("the composite resource", i, c.composite); err != nil {
+				return err
+			}
+		}
+		for _, name := range sortedKeys(c.composed) {
+			if err := check(fmt.Sprintf("composed resource %q", name), i, c.composed[name]); err != nil {
+				return err
+			}
+		}
+		for _, key := range sortedKeys(c.context) {
+			if err := check(fmt.Sprintf("context field %q", key), i, c.context[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply writes the changed resources and context fields to res.
+func (c responseChanges) apply(res ServerFunctionResponse) error {
+	if c.composite != nil {
+		res.SetCompositeRaw(c.composite)
+	}
+	for name, r := range c.composed {
+		res.SetComposedRaw(name, r)
+	}
+	for key, v := range c.context {
+		if err := res.SetContextField(key, v.AsInterface()); err != nil {
+			return errors.Wrapf(err, "cannot set context field %q", key)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func resultsEqual(a, b []*fnapi.Result) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
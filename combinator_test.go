@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCombinators(t *testing.T) {
+	// setComposed sets a composed resource with the given name and its input
+	// as spec.
+	setComposed := func(name string) ServerFunction {
+		return testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+			input := map[string]any{}
+			if err := req.GetInput(&input); err != nil {
+				return err
+			}
+			s, err := structpb.NewStruct(map[string]any{"spec": input})
+			if err != nil {
+				return err
+			}
+			res.SetComposedRaw(name, &fnapi.Resource{Resource: s})
+			return nil
+		})
+	}
+	// increment increments the context field "count".
+	increment := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		r := res.(*RunServerFunctionResponse)
+		return res.SetContextField("count", r.DesiredContext.GetFields()["count"].GetNumberValue()+1)
+	})
+	// item sets a composed resource named by the name field of its input.
+	item := testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+		input := struct {
+			Name string `json:"name"`
+		}{}
+		if err := req.GetInput(&input); err != nil {
+			return err
+		}
+		res.SetComposedRaw(input.Name, &fnapi.Resource{Resource: &structpb.Struct{}})
+		return nil
+	})
+	warn := func(msg string) ServerFunction {
+		return testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+			r := res.(*RunServerFunctionResponse)
+			res.SetNativeResults(append(r.Results, &fnapi.Result{Severity: fnapi.Severity_SEVERITY_WARNING, Message: msg}))
+			return nil
+		})
+	}
+	fail := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return errors.New("boom")
+	})
+	isXR := func(req ServerFunctionRequest, _ ServerFunctionResponse) bool {
+		return req.GetNativeRequest().GetObserved().GetComposite().GetResource().GetFields()["kind"].GetStringValue() == "XR"
+	}
+	isOther := func(req ServerFunctionRequest, res ServerFunctionResponse) bool {
+		return !isXR(req, res)
+	}
+
+	cases := map[string]struct {
+		fn      ServerFunction
+		input   map[string]any
+		want    *fnapi.State
+		ctx     map[string]any
+		results []*fnapi.Result
+		err     bool
+	}{
+		"Chain": {
+			fn:  Chain(increment, increment, increment),
+			ctx: map[string]any{"count": 3},
+		},
+		"ChainError": {
+			fn:  Chain(increment, fail, increment),
+			err: true,
+		},
+		"When": {
+			fn:  Chain(When(isXR, increment), When(isOther, increment)),
+			ctx: map[string]any{"count": 1},
+		},
+		"ForEach": {
+			fn:    ForEach("spec.buckets", item),
+			input: map[string]any{"spec": map[string]any{"buckets": []any{map[string]any{"name": "a"}, map[string]any{"name": "b"}}}},
+			want: &fnapi.State{Resources: map[string]*fnapi.Resource{
+				"a": {Resource: mustStruct(t, map[string]any{})},
+				"b": {Resource: mustStruct(t, map[string]any{})},
+			}},
+		},
+		"ForEachMissingList": {
+			fn: ForEach("spec.buckets", fail),
+		},
+		"ForEachNoList": {
+			fn:    ForEach("spec", item),
+			input: map[string]any{"spec": "invalid"},
+			err:   true,
+		},
+		"Parallel": {
+			fn:    Parallel(setComposed("a"), Chain(increment, setComposed("b")), warn("first"), warn("second")),
+			input: map[string]any{"size": 1},
+			want: &fnapi.State{Resources: map[string]*fnapi.Resource{
+				"a": {Resource: mustStruct(t, map[string]any{"spec": map[string]any{"size": 1}})},
+				"b": {Resource: mustStruct(t, map[string]any{"spec": map[string]any{"size": 1}})},
+			}},
+			ctx: map[string]any{"count": 1},
+			results: []*fnapi.Result{
+				{Severity: fnapi.Severity_SEVERITY_WARNING, Message: "first"},
+				{Severity: fnapi.Severity_SEVERITY_WARNING, Message: "second"},
+			},
+		},
+		"ParallelSameValue": {
+			fn:    Parallel(increment, increment, setComposed("a"), setComposed("a")),
+			input: map[string]any{"size": 1},
+			want: &fnapi.State{Resources: map[string]*fnapi.Resource{
+				"a": {Resource: mustStruct(t, map[string]any{"spec": map[string]any{"size": 1}})},
+			}},
+			ctx: map[string]any{"count": 1},
+		},
+		"ParallelError": {
+			fn:  Parallel(increment, fail),
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := NewServer(WithFunction("combined", tc.fn))
+			req := newTestRequest(t, "combined", tc.input)
+			req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{"kind": "XR"})}
+			res, err := srv.RunFunction(context.Background(), req)
+			if tc.err != (err != nil) {
+				t.Fatalf("Expected error %t but got %v", tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			want := tc.want
+			if want == nil {
+				want = &fnapi.State{}
+			}
+			if diff := cmp.Diff(want, res.GetDesired(), protocmp.Transform()); diff != "" {
+				t.Errorf("Desired: -want +got\n%s", diff)
+			}
+			var wantCtx *structpb.Struct
+			if tc.ctx != nil {
+				wantCtx = mustStruct(t, tc.ctx)
+			}
+			if diff := cmp.Diff(wantCtx, res.GetContext(), protocmp.Transform()); diff != "" {
+				t.Errorf("Context: -want +got\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.results, res.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("Results: -want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParallelConflict(t *testing.T) {
+	setContext := func(key string, value any) ServerFunction {
+		return testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+			return res.SetContextField(key, value)
+		})
+	}
+	setComposed := func(name, kind string) ServerFunction {
+		return testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+			res.SetComposedRaw(name, &fnapi.Resource{Resource: &structpb.Struct{Fields: map[string]*structpb.Value{
+				"kind": structpb.NewStringValue(kind),
+			}}})
+			return nil
+		})
+	}
+
+	cases := map[string]struct {
+		fn      ServerFunction
+		wantErr string
+	}{
+		"Composed": {
+			fn:      Parallel(setComposed("a", "Bucket"), setContext("key", "value"), setComposed("a", "Queue")),
+			wantErr: `parallel functions 0 and 2 set composed resource "a" differently`,
+		},
+		"Context": {
+			fn:      Parallel(setContext("key", "a"), setContext("key", "b")),
+			wantErr: `parallel functions 0 and 1 set context field "key" differently`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res := &RunServerFunctionResponse{}
+			req := &RunServerFunctionRequest{Req: &fnapi.RunFunctionRequest{}}
+			err := tc.fn.Run(context.Background(), req, res)
+			if !IsErrorConflict(err) {
+				t.Fatalf("Expected conflict error but got %v", err)
+			}
+			if err.Error() != tc.wantErr {
+				t.Errorf("Expected error %q but got %q", tc.wantErr, err.Error())
+			}
+			if diff := cmp.Diff(&RunServerFunctionResponse{}, res, protocmp.Transform()); diff != "" {
+				t.Errorf("Expected response to be unchanged: -want +got\n%s", diff)
+			}
+		})
+	}
+}
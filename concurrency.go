@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type errSaturated struct {
+	name string
+}
+
+func (e errSaturated) Error() string {
+	return fmt.Sprintf("server function %q is saturated", e.name)
+}
+
+// GRPCStatus marks the error as retryable for gRPC clients.
+func (e errSaturated) GRPCStatus() *status.Status {
+	return status.New(codes.ResourceExhausted, e.Error())
+}
+
+// IsErrorSaturated returns true if a ServerFunction call was rejected
+// because the ServerFunction reached its concurrency and queue limits.
+func IsErrorSaturated(err error) bool {
+	return errors.As(err, &errSaturated{})
+}
+
+// WithMaxConcurrency limits the number of concurrent invocations of a
+// ServerFunction to maxConcurrent.
+//
+// Up to maxQueued further invocations wait for a free slot. Invocations
+// beyond that are rejected with a retryable ResourceExhausted error. The
+// time an invocation waits counts against its timeout. An invocation holds
+// its slot until the ServerFunction returns, even if the
+// call was aborted by its timeout. Invocations are not limited if
+// maxConcurrent is zero or less.
+func WithMaxConcurrency(maxConcurrent, maxQueued int) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.limiter = newLimiter(maxConcurrent, maxQueued)
+	}
+}
+
+// FunctionStats are runtime statistics of a registered ServerFunction.
+type FunctionStats struct {
+	// InFlight is the number of invocations that are currently running.
+	InFlight int
+
+	// Queued is the number of invocations that wait for a free slot.
+	Queued int
+
+	// Rejected is the total number of invocations that were rejected due to
+	// concurrency limits.
+	Rejected int
+}
+
+// Stats returns the runtime statistics of all ServerFunctions that have
+// concurrency limits configured.
+func (s *Server) Stats() map[string]FunctionStats {
+	stats := map[string]FunctionStats{}
+	for name, reg := range s.snapshot() {
+		if reg.limiter != nil {
+			stats[name] = reg.limiter.stats()
+		}
+	}
+	return stats
+}
+
+// limiter limits the number of concurrent invocations of a function.
+type limiter struct {
+	slots     chan struct{}
+	maxQueued int64
+	queued    atomic.Int64
+	rejected  atomic.Int64
+}
+
+// newLimiter returns a limiter, or nil if maxConcurrent is zero or less.
+func newLimiter(maxConcurrent, maxQueued int) *limiter {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+	return &limiter{
+		slots:     make(chan struct{}, maxConcurrent),
+		maxQueued: int64(maxQueued),
+	}
+}
+
+// hasLimits returns true if l exists and has the given limits.
+func (l *limiter) hasLimits(maxConcurrent, maxQueued int) bool {
+	return l != nil && cap(l.slots) == maxConcurrent && l.maxQueued == int64(maxQueued)
+}
+
+// acquire blocks until a slot is free and returns a function that releases
+// the slot again. It fails if the queue is full or ctx is done.
+func (l *limiter) acquire(ctx context.Context, name string) (func(), error) {
+	release := func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if l.queued.Add(1) > l.maxQueued {
+		l.queued.Add(-1)
+		l.rejected.Add(1)
+		return nil, errSaturated{name: name}
+	}
+	defer l.queued.Add(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *limiter) stats() FunctionStats {
+	return FunctionStats{
+		InFlight: len(l.slots),
+		Queued:   int(l.queued.Load()),
+		Rejected: int(l.rejected.Load()),
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunFunctionMaxConcurrency(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	blockFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		started <- struct{}{}
+		<-unblock
+		return nil
+	})
+	srv := NewServer(WithFunction("block", blockFunction, WithMaxConcurrency(1, 1)))
+
+	wg := sync.WaitGroup{}
+	run := func() {
+		defer wg.Done()
+		if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "block", nil)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The first call occupies the only slot.
+	wg.Add(1)
+	go run()
+	<-started
+
+	// The second call waits in the queue.
+	wg.Add(1)
+	go run()
+	for srv.Stats()["block"].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The third call is rejected.
+	_, err := srv.RunFunction(context.Background(), newTestRequest(t, "block", nil))
+	if !IsErrorSaturated(err) {
+		t.Errorf("Expected saturated error but got %v", err)
+	}
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("Expected code %s but got %s", codes.ResourceExhausted, code)
+	}
+	if diff := cmp.Diff(FunctionStats{InFlight: 1, Queued: 1, Rejected: 1}, srv.Stats()["block"]); diff != "" {
+		t.Errorf("Stats: -want +got\n%s", diff)
+	}
+
+	unblock <- struct{}{}
+	<-started
+	unblock <- struct{}{}
+	wg.Wait()
+
+	if diff := cmp.Diff(FunctionStats{Rejected: 1}, srv.Stats()["block"]); diff != "" {
+		t.Errorf("Stats: -want +got\n%s", diff)
+	}
+}
+
+func TestRunFunctionUnlimitedConcurrency(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	blockFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		started <- struct{}{}
+		<-unblock
+		return nil
+	})
+	srv := NewServer(WithFunction("block", blockFunction, WithMaxConcurrency(0, 0)))
+
+	// Both calls run at the same time.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "block", nil)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	<-started
+	<-started
+	close(unblock)
+	wg.Wait()
+
+	if _, ok := srv.Stats()["block"]; ok {
+		t.Error("Expected no stats of a function without concurrency limit")
+	}
+}
+
+func TestRunFunctionMaxConcurrencyTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	returned := make(chan struct{}, 2)
+	// ignoreCancel keeps running after its timeout.
+	ignoreCancel := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		defer func() { returned <- struct{}{} }()
+		<-unblock
+		return nil
+	})
+	srv := NewServer(WithFunction("slow", ignoreCancel, WithMaxConcurrency(1, 0), WithTimeout(10*time.Millisecond)))
+
+	res, err := srv.RunFunction(context.Background(), newTestRequest(t, "slow", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.GetResults()[0].GetSeverity(); got != fnapi.Severity_SEVERITY_FATAL {
+		t.Fatalf("Expected fatal result but got %s", got)
+	}
+
+	// The abandoned call still holds the only slot.
+	if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "slow", nil)); !IsErrorSaturated(err) {
+		t.Errorf("Expected saturated error but got %v", err)
+	}
+
+	close(unblock)
+	<-returned
+	for srv.Stats()["slow"].InFlight != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	res, err = srv.RunFunction(context.Background(), newTestRequest(t, "slow", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.GetResults()) != 0 {
+		t.Errorf("Expected no results but got %v", res.GetResults())
+	}
+}
+
+func TestRunFunctionMaxConcurrencyQueueTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	// ignoreCancel keeps running after its timeout.
+	ignoreCancel := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		<-unblock
+		return nil
+	})
+	srv := NewServer(WithFunction("slow", ignoreCancel, WithMaxConcurrency(1, 1), WithTimeout(10*time.Millisecond)))
+	defer close(unblock)
+
+	// The first call times out but keeps the only slot. The second call
+	// times out while waiting for it.
+	for i := 0; i < 2; i++ {
+		res, err := srv.RunFunction(context.Background(), newTestRequest(t, "slow", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `server function "slow" timed out after 10ms`
+		if got := res.GetResults()[0]; got.GetSeverity() != fnapi.Severity_SEVERITY_FATAL || got.GetMessage() != want {
+			t.Errorf("Call %d: expected fatal result %q but got %v", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+type errDisabled struct {
+	name string
+}
+
+func (e errDisabled) Error() string {
+	return fmt.Sprintf("server function %q is disabled", e.name)
+}
+
+// IsErrorDisabled returns true if a ServerFunction call was rejected because
+// the ServerFunction is disabled by the server configuration.
+func IsErrorDisabled(err error) bool {
+	return errors.As(err, &errDisabled{})
+}
+
+// Config is the declarative configuration of a Server.
+type Config struct {
+	// Functions configures registered ServerFunctions by name.
+	Functions map[string]FunctionConfig `json:"functions,omitempty"`
+}
+
+// FunctionConfig configures a registered ServerFunction. Unset fields keep
+// the values the ServerFunction was registered with.
+type FunctionConfig struct {
+	// Disabled ServerFunctions return a fatal result when called.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Aliases are additional names the ServerFunction can be called with,
+	// besides those it was registered with.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Timeout of the ServerFunction.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// MaxConcurrency limits the number of concurrent invocations. Zero
+	// removes the limit.
+	MaxConcurrency *int `json:"maxConcurrency,omitempty"`
+
+	// MaxQueued limits the number of invocations waiting for a free slot.
+	MaxQueued *int `json:"maxQueued,omitempty"`
+
+	// DefaultInput is merged into the input of every call. Values set by
+	// the Composition take precedence.
+	DefaultInput map[string]any `json:"defaultInput,omitempty"`
+}
+
+// LoadConfig reads a Config from a YAML or JSON file. Unknown fields are
+// rejected.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path) //nolint:gosec // Reading user supplied files is intended.
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read config %q", path)
+	}
+	cfg := &Config{}
+	if err := sigsyaml.UnmarshalStrict(raw, cfg); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode config %q", path)
+	}
+	return cfg, nil
+}
+
+// ApplyConfig validates cfg against the registered ServerFunctions and
+// applies it. The previous configuration is replaced entirely, a nil cfg
+// restores the registered ServerFunctions.
+//
+// ApplyConfig is safe to call while the Server is serving requests.
+func (s *Server) ApplyConfig(cfg *Config) error {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if errs := cfg.validate(s.registered); len(errs) > 0 {
+		return errors.Wrap(errs.ToAggregate(), "invalid config")
+	}
+
+	current := s.snapshot()
+	functions := make(map[string]*registeredFunction, len(s.registered))
+	aliases := registeredAliases(s.registered)
+	for name, reg := range s.registered {
+		fc := cfg.Functions[name]
+		c := *reg
+		c.disabled = fc.Disabled
+		if fc.Timeout != nil {
+			c.timeout = fc.Timeout.Duration
+		}
+		if fc.MaxConcurrency != nil {
+			c.limiter = nil
+			if *fc.MaxConcurrency > 0 {
+				// Keep the current limiter and its statistics if the
+				// limits did not change.
+				c.limiter = newLimiter(*fc.MaxConcurrency, ptrValue(fc.MaxQueued))
+				if cur, ok := current[name]; ok && cur.limiter.hasLimits(*fc.MaxConcurrency, ptrValue(fc.MaxQueued)) {
+					c.limiter = cur.limiter
+				}
+			}
+		}
+		if fc.DefaultInput != nil {
+			raw, err := json.Marshal(fc.DefaultInput)
+			if err != nil {
+				return errors.Wrapf(err, "cannot encode default input of server function %q", name)
+			}
+			c.defaultInput = raw
+		}
+		for _, a := range fc.Aliases {
+			aliases[a] = name
+		}
+		functions[name] = &c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.functions = functions
+	s.aliases = aliases
+	return nil
+}
+
+// WatchConfig applies the config file at path and polls it in the given
+// interval to apply it again whenever it changes. Invalid configs are logged
+// and ignored. Blocks until ctx is done.
+func (s *Server) WatchConfig(ctx context.Context, path string, interval time.Duration) {
+	var last []byte
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		last = s.reloadConfig(path, last)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// reloadConfig applies the config file at path if its content differs from
+// last and returns its content.
+func (s *Server) reloadConfig(path string, last []byte) []byte {
+	raw, err := os.ReadFile(path) //nolint:gosec // Reading user supplied files is intended.
+	if err != nil {
+		s.log.Info("Cannot read config", "path", path, "error", err)
+		return last
+	}
+	if bytes.Equal(raw, last) {
+		return last
+	}
+	cfg, err := LoadConfig(path)
+	if err == nil {
+		err = s.ApplyConfig(cfg)
+	}
+	if err != nil {
+		s.log.Info("Cannot reload config", "path", path, "error", err)
+		return raw
+	}
+	s.log.Info("Reloaded config", "path", path)
+	return raw
+}
+
+// validate cfg against the registered ServerFunctions.
+func (cfg *Config) validate(registered map[string]*registeredFunction) field.ErrorList {
+	errs := field.ErrorList{}
+	names := make([]string, 0, len(cfg.Functions))
+	for name := range cfg.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	aliases := registeredAliases(registered)
+	for _, name := range names {
+		fc := cfg.Functions[name]
+		path := field.NewPath("functions").Key(name)
+		if _, ok := registered[name]; !ok {
+			errs = append(errs, field.NotFound(path, name))
+			continue
+		}
+		for i, a := range fc.Aliases {
+			switch _, isFunction := registered[a]; {
+			case isFunction:
+				errs = append(errs, field.Duplicate(path.Child("aliases").Index(i), a))
+			case aliases[a] != "":
+				errs = append(errs, field.Invalid(path.Child("aliases").Index(i), a, fmt.Sprintf("alias is already used by server function %q", aliases[a])))
+			default:
+				aliases[a] = name
+			}
+		}
+		if fc.Timeout != nil && fc.Timeout.Duration < 0 {
+			errs = append(errs, field.Invalid(path.Child("timeout"), fc.Timeout.Duration.String(), "must not be negative"))
+		}
+		if fc.MaxConcurrency != nil && *fc.MaxConcurrency < 0 {
+			errs = append(errs, field.Invalid(path.Child("maxConcurrency"), *fc.MaxConcurrency, "must not be negative"))
+		}
+		if fc.MaxQueued != nil {
+			if *fc.MaxQueued < 0 {
+				errs = append(errs, field.Invalid(path.Child("maxQueued"), *fc.MaxQueued, "must not be negative"))
+			}
+			if ptrValue(fc.MaxConcurrency) == 0 {
+				errs = append(errs, field.Forbidden(path.Child("maxQueued"), "requires maxConcurrency"))
+			}
+		}
+	}
+	return errs
+}
+
+// mergeDefaultInput merges the JSON object input into the JSON object
+// defaults. Values of input take precedence.
+func mergeDefaultInput(defaults, input []byte) ([]byte, error) {
+	if len(defaults) == 0 {
+		return input, nil
+	}
+	var d, in any
+	if err := unmarshalNumbers(defaults, &d); err != nil {
+		return nil, errors.Wrap(err, "cannot decode default input")
+	}
+	if len(input) > 0 {
+		if err := unmarshalNumbers(input, &in); err != nil {
+			return nil, errors.Wrap(err, "cannot decode input")
+		}
+	}
+	return json.Marshal(mergeValues(d, in))
+}
+
+// mergeValues deep merges override into base. Objects are merged, all other
+// values of override replace those of base.
+func mergeValues(base, override any) any {
+	bm, bok := base.(map[string]any)
+	om, ook := override.(map[string]any)
+	switch {
+	case override == nil:
+		return base
+	case !bok || !ook:
+		return override
+	}
+	out := make(map[string]any, len(bm)+len(om))
+	for k, v := range bm {
+		out[k] = v
+	}
+	for k, v := range om {
+		out[k] = mergeValues(bm[k], v)
+	}
+	return out
+}
+
+func ptrValue(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cases := map[string]struct {
+		config string
+		want   *Config
+		err    bool
+	}{
+		"Valid": {
+			config: `
+functions:
+  my-function:
+    disabled: true
+    aliases: [old-function]
+    timeout: 5s
+    maxConcurrency: 2
+    defaultInput:
+      region: eu-central-1
+`,
+			want: &Config{Functions: map[string]FunctionConfig{
+				"my-function": {
+					Disabled:       true,
+					Aliases:        []string{"old-function"},
+					Timeout:        mustDuration(t, "5s"),
+					MaxConcurrency: ptr.To(2),
+					DefaultInput:   map[string]any{"region": "eu-central-1"},
+				},
+			}},
+		},
+		"UnknownField": {
+			config: `
+functions:
+  my-function:
+    disable: true
+`,
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tc.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadConfig(path)
+			if tc.err != (err != nil) {
+				t.Fatalf("Expected error %t but got %v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyConfigValidation(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	srv := NewServer(WithFunction("a", noop), WithFunction("b", noop))
+
+	cases := map[string]struct {
+		config *Config
+		err    bool
+	}{
+		"Valid": {
+			config: &Config{Functions: map[string]FunctionConfig{
+				"a": {Aliases: []string{"c"}, MaxConcurrency: ptr.To(1), MaxQueued: ptr.To(1)},
+			}},
+		},
+		"Nil": {},
+		"UnknownFunction": {
+			config: &Config{Functions: map[string]FunctionConfig{"missing": {}}},
+			err:    true,
+		},
+		"AliasShadowsFunction": {
+			config: &Config{Functions: map[string]FunctionConfig{"a": {Aliases: []string{"b"}}}},
+			err:    true,
+		},
+		"AliasUsedTwice": {
+			config: &Config{Functions: map[string]FunctionConfig{
+				"a": {Aliases: []string{"c"}},
+				"b": {Aliases: []string{"c"}},
+			}},
+			err: true,
+		},
+		"NegativeTimeout": {
+			config: &Config{Functions: map[string]FunctionConfig{"a": {Timeout: mustDuration(t, "-1s")}}},
+			err:    true,
+		},
+		"QueueWithoutConcurrency": {
+			config: &Config{Functions: map[string]FunctionConfig{"a": {MaxQueued: ptr.To(1)}}},
+			err:    true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := srv.ApplyConfig(tc.config)
+			if tc.err != (err != nil) {
+				t.Fatalf("Expected error %t but got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	// inputFunction returns its input as context field "input".
+	inputFunction := testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+		input := map[string]any{}
+		if err := req.GetInput(&input); err != nil {
+			return err
+		}
+		return res.SetContextField("input", input)
+	})
+	srv := NewServer(
+		WithFunction("input", inputFunction, WithTimeout(time.Minute)),
+		WithFunction("other", inputFunction),
+	)
+	err := srv.ApplyConfig(&Config{Functions: map[string]FunctionConfig{
+		"input": {
+			Aliases:      []string{"old-input"},
+			Timeout:      mustDuration(t, "5s"),
+			DefaultInput: map[string]any{"region": "eu-central-1", "size": map[string]any{"min": 1, "max": 2}},
+		},
+		"other": {Disabled: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FunctionInfo{
+		{Name: "input", Timeout: 5 * time.Second, Aliases: []string{"old-input"}},
+		{Name: "other", Disabled: true},
+	}
+	if diff := cmp.Diff(want, srv.Functions()); diff != "" {
+		t.Errorf("Functions: -want +got\n%s", diff)
+	}
+
+	type result struct {
+		input    map[string]any
+		severity fnapi.Severity
+	}
+	cases := map[string]struct {
+		function string
+		input    map[string]any
+		want     result
+	}{
+		"DefaultInputMerged": {
+			function: "input",
+			input:    map[string]any{"size": map[string]any{"max": 3}},
+			want: result{input: map[string]any{
+				"region": "eu-central-1",
+				"size":   map[string]any{"min": float64(1), "max": float64(3)},
+			}},
+		},
+		"Alias": {
+			function: "old-input",
+			want: result{input: map[string]any{
+				"region": "eu-central-1",
+				"size":   map[string]any{"min": float64(1), "max": float64(2)},
+			}},
+		},
+		"Disabled": {
+			function: "other",
+			want:     result{severity: fnapi.Severity_SEVERITY_FATAL},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := srv.RunFunction(context.Background(), newTestRequest(t, tc.function, tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var severity fnapi.Severity
+			if len(res.GetResults()) > 0 {
+				severity = res.GetResults()[0].GetSeverity()
+			}
+			if severity != tc.want.severity {
+				t.Errorf("Expected severity %s but got %s", tc.want.severity, severity)
+			}
+			var got map[string]any
+			if v, ok := res.GetContext().GetFields()["input"]; ok {
+				got = v.GetStructValue().AsMap()
+			}
+			if diff := cmp.Diff(tc.want.input, got); diff != "" {
+				t.Errorf("Input: -want +got\n%s", diff)
+			}
+		})
+	}
+
+	// Restoring the registered functions removes the config.
+	if err := srv.ApplyConfig(nil); err != nil {
+		t.Fatal(err)
+	}
+	want = []FunctionInfo{
+		{Name: "input", Timeout: time.Minute},
+		{Name: "other"},
+	}
+	if diff := cmp.Diff(want, srv.Functions()); diff != "" {
+		t.Errorf("Functions: -want +got\n%s", diff)
+	}
+}
+
+func TestMergeDefaultInput(t *testing.T) {
+	cases := map[string]struct {
+		defaults string
+		input    string
+		want     string
+	}{
+		"NoDefaults": {
+			input: `{"a":1}`,
+			want:  `{"a":1}`,
+		},
+		"NoInput": {
+			defaults: `{"a":1}`,
+			want:     `{"a":1}`,
+		},
+		"Merged": {
+			defaults: `{"a":{"b":1,"c":2},"d":"e"}`,
+			input:    `{"a":{"c":3}}`,
+			want:     `{"a":{"b":1,"c":3},"d":"e"}`,
+		},
+		"NumbersNotRounded": {
+			defaults: `{"big":12345678901234567890}`,
+			input:    `{"int":9007199254740993}`,
+			want:     `{"big":12345678901234567890,"int":9007199254740993}`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var defaults, input []byte
+			if tc.defaults != "" {
+				defaults = []byte(tc.defaults)
+			}
+			if tc.input != "" {
+				input = []byte(tc.input)
+			}
+			got, err := mergeDefaultInput(defaults, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	srv := NewServer(WithFunction("noop", noop))
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("functions: {}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.WatchConfig(ctx, path, time.Millisecond)
+
+	if err := os.WriteFile(path, []byte("functions: {noop: {disabled: true}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !srv.Functions()[0].Disabled {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected config to be reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func mustDuration(t *testing.T, s string) *metav1.Duration {
+	t.Helper()
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &metav1.Duration{Duration: d}
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossplane-function-server/apis/v1beta1"
+)
+
+// DefaultDebugAddress is the default address of the debug gateway. It only
+// accepts connections from localhost.
+const DefaultDebugAddress = "localhost:8081"
+
+// maxDebugRequestBytes limits the size of debug gateway request bodies.
+const maxDebugRequestBytes = 10 << 20
+
+// DebugRequest is a simplified RunFunctionRequest that calls a single
+// ServerFunction.
+type DebugRequest struct {
+	// XR is the observed composite resource.
+	XR map[string]any `json:"xr"`
+
+	// Observed are the observed composed resources by name.
+	Observed map[string]map[string]any `json:"observed,omitempty"`
+
+	// Context of the request.
+	Context map[string]any `json:"context,omitempty"`
+
+	// Input of the ServerFunction.
+	Input map[string]any `json:"input,omitempty"`
+}
+
+// DebugHandler returns an HTTP handler that calls srv with JSON or YAML
+// encoded requests and responds with YAML, or JSON if requested via the
+// Accept header. It serves the following endpoints:
+//
+//   - GET /v1/functions lists all ServerFunctions.
+//   - POST /v1/run accepts a RunFunctionRequest.
+//   - POST /v1/functions/{name} accepts a DebugRequest.
+//
+// The handler is meant for debugging and must not be exposed publicly.
+func DebugHandler(srv *Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/functions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		type function struct {
+			Name        string   `json:"name"`
+			Version     string   `json:"version,omitempty"`
+			Aliases     []string `json:"aliases,omitempty"`
+			Deprecation string   `json:"deprecation,omitempty"`
+		}
+		fns := []function{}
+		for _, fn := range srv.Functions() {
+			fns = append(fns, function{Name: fn.Name, Version: fn.Version, Aliases: fn.Aliases, Deprecation: fn.Deprecation})
+		}
+		raw, _ := json.Marshal(fns)
+		writeDebugResponse(w, r, raw)
+	})
+	mux.HandleFunc("/v1/run", func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := readDebugRequest(w, r)
+		if !ok {
+			return
+		}
+		req := &fnapi.RunFunctionRequest{}
+		if err := protojson.Unmarshal(raw, req); err != nil {
+			http.Error(w, errors.Wrap(err, "cannot decode RunFunctionRequest").Error(), http.StatusBadRequest)
+			return
+		}
+		runDebugRequest(w, r, srv, req)
+	})
+	mux.HandleFunc("/v1/functions/", func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := readDebugRequest(w, r)
+		if !ok {
+			return
+		}
+		dr := &DebugRequest{}
+		if err := json.Unmarshal(raw, dr); err != nil {
+			http.Error(w, errors.Wrap(err, "cannot decode request").Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := dr.toRunFunctionRequest(strings.TrimPrefix(r.URL.Path, "/v1/functions/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		runDebugRequest(w, r, srv, req)
+	})
+	return mux
+}
+
+// ServeDebug serves the DebugHandler of srv on an HTTP server listening on
+// address. Blocks until the server returns an error.
+func ServeDebug(address string, srv *Server) error {
+	s := &http.Server{
+		Addr:              address,
+		Handler:           DebugHandler(srv),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return errors.Wrap(s.ListenAndServe(), "cannot serve debug gateway")
+}
+
+// toRunFunctionRequest converts a DebugRequest into a RunFunctionRequest
+// that calls the named ServerFunction.
+func (dr *DebugRequest) toRunFunctionRequest(name string) (*fnapi.RunFunctionRequest, error) {
+	input, err := structpb.NewStruct(map[string]any{
+		"apiVersion": v1beta1.GroupVersion.String(),
+		"kind":       v1beta1.ServerInputKind,
+		"spec": map[string]any{
+			"functionName": name,
+			"input":        dr.Input,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot convert input")
+	}
+	req := &fnapi.RunFunctionRequest{
+		Input: input,
+		Observed: &fnapi.State{
+			Composite: &fnapi.Resource{},
+			Resources: map[string]*fnapi.Resource{},
+		},
+		Desired: &fnapi.State{},
+	}
+	if req.Observed.Composite.Resource, err = structpb.NewStruct(dr.XR); err != nil {
+		return nil, errors.Wrap(err, "cannot convert composite resource")
+	}
+	for n, o := range dr.Observed {
+		s, err := structpb.NewStruct(o)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert observed resource %q", n)
+		}
+		req.Observed.Resources[n] = &fnapi.Resource{Resource: s}
+	}
+	if dr.Context != nil {
+		if req.Context, err = structpb.NewStruct(dr.Context); err != nil {
+			return nil, errors.Wrap(err, "cannot convert context")
+		}
+	}
+	return req, nil
+}
+
+// readDebugRequest reads a JSON or YAML request body and returns it as JSON.
+func readDebugRequest(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDebugRequestBytes))
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "cannot read request").Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	raw, err := sigsyaml.YAMLToJSON(body)
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "cannot decode request").Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return raw, true
+}
+
+func runDebugRequest(w http.ResponseWriter, r *http.Request, srv *Server, req *fnapi.RunFunctionRequest) {
+	res, err := srv.RunFunction(r.Context(), req)
+	switch {
+	case IsErrorNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	raw, err := protojson.Marshal(res)
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "cannot encode response").Error(), http.StatusInternalServerError)
+		return
+	}
+	writeDebugResponse(w, r, raw)
+}
+
+// writeDebugResponse writes a JSON response as YAML, unless the client
+// accepts JSON only.
+func writeDebugResponse(w http.ResponseWriter, r *http.Request, raw []byte) {
+	if r.Header.Get("Accept") == "application/json" {
+		// Indent to get a stable output since protojson randomizes whitespace.
+		buf := &bytes.Buffer{}
+		if err := json.Indent(buf, raw, "", "  "); err != nil {
+			http.Error(w, errors.Wrap(err, "cannot encode response").Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+	out, err := sigsyaml.JSONToYAML(raw)
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "cannot encode response").Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(out)
+}
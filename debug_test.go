@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDebugHandler(t *testing.T) {
+	// echoFunction copies the XR name and its input into the context.
+	echoFunction := testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+		input := map[string]any{}
+		if err := req.GetInput(&input); err != nil {
+			return err
+		}
+		xr := req.GetNativeRequest().GetObserved().GetComposite().GetResource().GetFields()
+		if err := res.SetContextField("xr", xr["metadata"].GetStructValue().GetFields()["name"].GetStringValue()); err != nil {
+			return err
+		}
+		return res.SetContextField("input", input)
+	})
+	ts := httptest.NewServer(DebugHandler(NewServer(WithFunction("echo", echoFunction, WithVersion("v1")))))
+	defer ts.Close()
+
+	type want struct {
+		status int
+		body   string
+	}
+	cases := map[string]struct {
+		method string
+		path   string
+		accept string
+		body   string
+		want   want
+	}{
+		"ListFunctions": {
+			method: http.MethodGet,
+			path:   "/v1/functions",
+			want: want{
+				status: http.StatusOK,
+				body:   "- name: echo\n  version: v1\n",
+			},
+		},
+		"CallFunctionYAML": {
+			method: http.MethodPost,
+			path:   "/v1/functions/echo",
+			body: `
+xr:
+  metadata:
+    name: example
+input:
+  greeting: hello
+`,
+			want: want{
+				status: http.StatusOK,
+				body: `context:
+  input:
+    greeting: hello
+  xr: example
+desired: {}
+`,
+			},
+		},
+		"CallFunctionJSON": {
+			method: http.MethodPost,
+			path:   "/v1/functions/echo",
+			accept: "application/json",
+			body:   `{"xr": {"metadata": {"name": "example"}}}`,
+			want: want{
+				status: http.StatusOK,
+				body: `{
+  "desired": {},
+  "context": {
+    "input": {},
+    "xr": "example"
+  }
+}`,
+			},
+		},
+		"RunFunctionRequest": {
+			method: http.MethodPost,
+			path:   "/v1/run",
+			body: `
+observed:
+  composite:
+    resource:
+      metadata:
+        name: raw
+input:
+  apiVersion: server.fn.crossplane.io/v1beta1
+  kind: ServerInput
+  spec:
+    functionName: echo
+    input:
+      greeting: hi
+`,
+			want: want{
+				status: http.StatusOK,
+				body: `context:
+  input:
+    greeting: hi
+  xr: raw
+desired: {}
+`,
+			},
+		},
+		"UnknownFunction": {
+			method: http.MethodPost,
+			path:   "/v1/functions/missing",
+			body:   `{}`,
+			want:   want{status: http.StatusNotFound},
+		},
+		"InvalidBody": {
+			method: http.MethodPost,
+			path:   "/v1/run",
+			body:   `observed: [`,
+			want:   want{status: http.StatusBadRequest},
+		},
+		"MethodNotAllowed": {
+			method: http.MethodGet,
+			path:   "/v1/run",
+			want:   want{status: http.StatusMethodNotAllowed},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close() //nolint:errcheck // Nothing to do if closing fails in tests.
+			body, _ := io.ReadAll(res.Body)
+			if res.StatusCode != tc.want.status {
+				t.Fatalf("Expected status %d but got %d: %s", tc.want.status, res.StatusCode, body)
+			}
+			if tc.want.body == "" {
+				return
+			}
+			if diff := cmp.Diff(tc.want.body, string(body)); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
@@ -2,9 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
 
-	"github.com/crossplane/function-sdk-go/logging"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -16,9 +14,7 @@ type MyFunctionInput struct {
 	Resources []interface{} `json:"resources"`
 }
 
-type MyFunction struct {
-	log logging.Logger
-}
+type MyFunction struct{}
 
 func (f *MyFunction) Run(ctx context.Context, req server.ServerFunctionRequest, res server.ServerFunctionResponse) error {
 	// ServerFunction input can be anything that unmarshalls from a JSON blob.
@@ -27,7 +23,9 @@ func (f *MyFunction) Run(ctx context.Context, req server.ServerFunctionRequest,
 		return errors.Wrap(err, "cannot parse input")
 	}
 
-	log.Default().Println("Calling function MyFunction")
+	// The logger is tagged with the function name, the composite resource and
+	// a request ID by the server.
+	server.LoggerFrom(ctx).Debug("Calling function MyFunction")
 
 	// For the sake of simplicity this example uses unstructured.Unstructured
 	// to deploy a standard K8s ClusterRole.
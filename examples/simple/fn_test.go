@@ -5,7 +5,6 @@ import (
 	_ "embed"
 	"testing"
 
-	"github.com/crossplane/function-sdk-go/logging"
 	fntesting "github.com/mistermx/crossplane-function-server/testing"
 )
 
@@ -22,7 +21,7 @@ var (
 
 func TestFunction(t *testing.T) {
 	fntesting.TestFunction(
-		t, &MyFunction{log: logging.NewNopLogger()},
+		t, &MyFunction{},
 		fntesting.WithObservedCompositeYAML(composite),
 		fntesting.WithInputYAML(input),
 		fntesting.ExpectDesiredResourcesYAML(expectedResources),
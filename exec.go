@@ -0,0 +1,287 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// execWaitDelay is the time an executable gets to close its output after its
+// context was canceled.
+const execWaitDelay = time.Second
+
+// ExecRequest is written to the stdin of the executable of an ExecFunction.
+type ExecRequest struct {
+	// Composite is the observed composite resource.
+	Composite map[string]any `json:"composite,omitempty"`
+
+	// Observed are the observed composed resources by name.
+	Observed map[string]map[string]any `json:"observed,omitempty"`
+
+	// Desired is the desired state of all previous steps.
+	Desired ExecState `json:"desired"`
+
+	// Input of the ServerFunction.
+	Input map[string]any `json:"input,omitempty"`
+
+	// Context of the pipeline.
+	Context map[string]any `json:"context,omitempty"`
+}
+
+// ExecState is the state of a composite resource and its composed
+// resources.
+type ExecState struct {
+	// Composite resource.
+	Composite map[string]any `json:"composite,omitempty"`
+
+	// Resources are the composed resources by name.
+	Resources map[string]map[string]any `json:"resources,omitempty"`
+}
+
+// ExecResponse is read from the stdout of the executable of an ExecFunction
+// as JSON or YAML.
+type ExecResponse struct {
+	// Composite replaces the desired composite resource if set.
+	Composite map[string]any `json:"composite,omitempty"`
+
+	// Resources are the desired composed resources to set by name.
+	Resources map[string]map[string]any `json:"resources,omitempty"`
+
+	// Context are the context fields to set.
+	Context map[string]any `json:"context,omitempty"`
+
+	// Results of the executable.
+	Results []ExecResult `json:"results,omitempty"`
+}
+
+// ExecResult is a result of an executable.
+type ExecResult struct {
+	// Severity is one of Normal, Warning or Fatal. Defaults to Normal.
+	Severity string `json:"severity,omitempty"`
+
+	// Message of the result.
+	Message string `json:"message"`
+}
+
+// An ExecOption configures an ExecFunction.
+type ExecOption func(fn *ExecFunction)
+
+// ExecArgs sets the arguments the executable is run with.
+func ExecArgs(args ...string) ExecOption {
+	return func(fn *ExecFunction) {
+		fn.args = args
+	}
+}
+
+// ExecEnv adds environment variables in the form key=value to the
+// environment of the server the executable is run with.
+func ExecEnv(env ...string) ExecOption {
+	return func(fn *ExecFunction) {
+		fn.env = append(fn.env, env...)
+	}
+}
+
+// ExecYAML writes the ExecRequest as YAML instead of JSON.
+func ExecYAML() ExecOption {
+	return func(fn *ExecFunction) {
+		fn.yaml = true
+	}
+}
+
+// ExecStderrWarnings returns every line the executable writes to stderr as a
+// warning result. By default stderr is only logged.
+func ExecStderrWarnings() ExecOption {
+	return func(fn *ExecFunction) {
+		fn.stderrWarnings = true
+	}
+}
+
+// An ExecFunction is a ServerFunction that runs an executable, e.g. a Python
+// or shell script, for every call.
+//
+// The executable receives an ExecRequest on stdin and must write an
+// ExecResponse to stdout. It fails if it exits with a non-zero code, in which
+// case its stderr is part of the returned error. The executable is killed if
+// the call is canceled, e.g. by the timeout set with WithTimeout.
+type ExecFunction struct {
+	path           string
+	args           []string
+	env            []string
+	yaml           bool
+	stderrWarnings bool
+}
+
+// NewExecFunction returns an ExecFunction that runs the executable at path.
+func NewExecFunction(path string, opts ...ExecOption) *ExecFunction {
+	fn := &ExecFunction{path: path}
+	for _, o := range opts {
+		o(fn)
+	}
+	return fn
+}
+
+// Run implements ServerFunction.
+func (f *ExecFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	stdin, err := f.request(req, res)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, f.path, f.args...) //nolint:gosec // Running the configured executable is intended.
+	cmd.Env = append(os.Environ(), f.env...)
+	cmd.WaitDelay = execWaitDelay
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err = cmd.Run()
+	log := LoggerFrom(ctx)
+	lines := outputLines(stderr.Bytes())
+	for _, l := range lines {
+		log.Debug("Executable wrote to stderr", "executable", f.path, "line", l)
+	}
+	if ctx.Err() != nil {
+		return errors.Wrapf(ctx.Err(), "executable %q was canceled", f.path)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "executable %q failed: %s", f.path, strings.Join(lines, "\n"))
+	}
+
+	out := &ExecResponse{}
+	if err := sigsyaml.Unmarshal(stdout.Bytes(), out); err != nil {
+		return errors.Wrapf(err, "cannot decode response of executable %q", f.path)
+	}
+	if f.stderrWarnings {
+		for _, l := range lines {
+			out.Results = append(out.Results, ExecResult{Severity: "Warning", Message: l})
+		}
+	}
+	return out.apply(res)
+}
+
+// request returns the encoded ExecRequest for a call.
+func (f *ExecFunction) request(req ServerFunctionRequest, res ServerFunctionResponse) ([]byte, error) {
+	in, err := newExecRequest(req, res)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode request")
+	}
+	if f.yaml {
+		raw, err = sigsyaml.JSONToYAML(raw)
+	}
+	return raw, errors.Wrap(err, "cannot encode request")
+}
+
+// newExecRequest returns the ExecRequest for a call.
+func newExecRequest(req ServerFunctionRequest, res ServerFunctionResponse) (*ExecRequest, error) {
+	native := req.GetNativeRequest()
+	in := &ExecRequest{
+		Composite: native.GetObserved().GetComposite().GetResource().AsMap(),
+		Observed:  resourceMaps(native.GetObserved().GetResources()),
+		Desired: ExecState{
+			Composite: native.GetDesired().GetComposite().GetResource().AsMap(),
+			Resources: resourceMaps(native.GetDesired().GetResources()),
+		},
+		Context: native.GetContext().AsMap(),
+	}
+	if r, ok := res.(*RunServerFunctionResponse); ok {
+		desired, fnCtx := r.desiredState(native)
+		in.Desired.Composite = desired.GetComposite().GetResource().AsMap()
+		in.Desired.Resources = resourceMaps(desired.GetResources())
+		in.Context = fnCtx.AsMap()
+	}
+	if err := req.GetInput(&in.Input); err != nil {
+		return nil, errors.Wrap(err, "cannot decode input")
+	}
+	return in, nil
+}
+
+// apply the response to res. Its results are appended to those of res.
+func (r *ExecResponse) apply(res ServerFunctionResponse) error {
+	if r.Composite != nil {
+		s, err := structpb.NewStruct(r.Composite)
+		if err != nil {
+			return errors.Wrap(err, "cannot convert composite resource to protobuf")
+		}
+		res.SetCompositeRaw(&fnapi.Resource{Resource: s})
+	}
+	for name, o := range r.Resources {
+		s, err := structpb.NewStruct(o)
+		if err != nil {
+			return errors.Wrapf(err, "cannot convert composed resource %q to protobuf", name)
+		}
+		res.SetComposedRaw(name, &fnapi.Resource{Resource: s})
+	}
+	for k, v := range r.Context {
+		if err := res.SetContextField(k, v); err != nil {
+			return errors.Wrapf(err, "cannot set context field %q", k)
+		}
+	}
+	if len(r.Results) == 0 {
+		return nil
+	}
+	// Keep the results of previous steps and functions.
+	var results []*fnapi.Result
+	if rr, ok := res.(*RunServerFunctionResponse); ok {
+		results = append(results, rr.Results...)
+	}
+	for _, result := range r.Results {
+		severity, err := execSeverity(result.Severity)
+		if err != nil {
+			return err
+		}
+		results = append(results, &fnapi.Result{Severity: severity, Message: result.Message})
+	}
+	res.SetNativeResults(results)
+	return nil
+}
+
+func execSeverity(s string) (fnapi.Severity, error) {
+	switch strings.ToLower(s) {
+	case "", "normal":
+		return fnapi.Severity_SEVERITY_NORMAL, nil
+	case "warning":
+		return fnapi.Severity_SEVERITY_WARNING, nil
+	case "fatal":
+		return fnapi.Severity_SEVERITY_FATAL, nil
+	default:
+		return fnapi.Severity_SEVERITY_UNSPECIFIED, errors.Errorf("unknown result severity %q", s)
+	}
+}
+
+// resourceMaps returns the given resources as maps by name.
+func resourceMaps(resources map[string]*fnapi.Resource) map[string]map[string]any {
+	if len(resources) == 0 {
+		return nil
+	}
+	out := make(map[string]map[string]any, len(resources))
+	for name, r := range resources {
+		out[name] = r.GetResource().AsMap()
+	}
+	return out
+}
+
+// outputLines returns the non-empty lines of the output of a process.
+func outputLines(out []byte) []string {
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		if l := strings.TrimSpace(s.Text()); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// writeScript writes an executable shell script and returns its path.
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fn.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700); err != nil { //nolint:gosec // The script must be executable.
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecFunction(t *testing.T) {
+	requestPath := filepath.Join(t.TempDir(), "request")
+	respond := writeScript(t, `cat > "$1"
+echo "running" >&2
+cat <<RESPONSE
+composite:
+  apiVersion: example.org/v1
+  kind: XR
+resources:
+  bucket:
+    apiVersion: s3.aws.upbound.io/v1beta1
+    kind: Bucket
+context:
+  greeting: hello
+results:
+- severity: Warning
+  message: careful
+RESPONSE
+`)
+	local := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		res.SetComposedRaw("local", &fnapi.Resource{Resource: mustStruct(t, map[string]any{"kind": "Local"})})
+		return nil
+	})
+	srv := NewServer(
+		WithFunction("local", local),
+		WithFunction("respond", NewExecFunction(respond, ExecArgs(requestPath))),
+		WithFunction("yaml", NewExecFunction(respond, ExecArgs(requestPath), ExecYAML(), ExecStderrWarnings())),
+		WithFunction("fail", NewExecFunction(writeScript(t, "echo boom >&2\nexit 1\n"))),
+		WithFunction("slow", NewExecFunction(writeScript(t, "exec sleep 10\n")), WithTimeout(100*time.Millisecond)),
+	)
+
+	steps := func(t *testing.T, names ...string) *fnapi.RunFunctionRequest {
+		t.Helper()
+		s := make([]any, len(names))
+		for i, n := range names {
+			s[i] = map[string]any{"functionName": n, "input": map[string]any{"step": n}}
+		}
+		req := newTestRequest(t, "", nil)
+		req.Input = mustStruct(t, map[string]any{
+			"apiVersion": "server.fn.crossplane.io/v1beta1",
+			"kind":       "ServerInput",
+			"spec":       map[string]any{"steps": s},
+		})
+		req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{"kind": "XR"})}
+		return req
+	}
+	response := &fnapi.RunFunctionResponse{
+		Desired: &fnapi.State{
+			Composite: &fnapi.Resource{Resource: mustStruct(t, map[string]any{"apiVersion": "example.org/v1", "kind": "XR"})},
+			Resources: map[string]*fnapi.Resource{
+				"local":  {Resource: mustStruct(t, map[string]any{"kind": "Local"})},
+				"bucket": {Resource: mustStruct(t, map[string]any{"apiVersion": "s3.aws.upbound.io/v1beta1", "kind": "Bucket"})},
+			},
+		},
+		Context: mustStruct(t, map[string]any{"greeting": "hello"}),
+		Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_WARNING, Message: "careful"}},
+	}
+
+	type want struct {
+		res     *fnapi.RunFunctionResponse
+		request *ExecRequest
+		yaml    bool
+		err     string
+	}
+	cases := map[string]struct {
+		req  *fnapi.RunFunctionRequest
+		want want
+	}{
+		"JSON": {
+			req: steps(t, "local", "respond"),
+			want: want{
+				res: response,
+				request: &ExecRequest{
+					Composite: map[string]any{"kind": "XR"},
+					Desired: ExecState{Resources: map[string]map[string]any{
+						"local": {"kind": "Local"},
+					}},
+					Input: map[string]any{"step": "respond"},
+				},
+			},
+		},
+		"YAMLWithStderrWarnings": {
+			req: steps(t, "yaml"),
+			want: want{
+				res: &fnapi.RunFunctionResponse{
+					Desired: &fnapi.State{
+						Composite: response.Desired.Composite,
+						Resources: map[string]*fnapi.Resource{"bucket": response.Desired.Resources["bucket"]},
+					},
+					Context: response.Context,
+					Results: []*fnapi.Result{
+						{Severity: fnapi.Severity_SEVERITY_WARNING, Message: "careful"},
+						{Severity: fnapi.Severity_SEVERITY_WARNING, Message: "running"},
+					},
+				},
+				request: &ExecRequest{
+					Composite: map[string]any{"kind": "XR"},
+					Input:     map[string]any{"step": "yaml"},
+				},
+				yaml: true,
+			},
+		},
+		"Failure": {
+			req:  steps(t, "fail"),
+			want: want{err: "boom"},
+		},
+		"Timeout": {
+			req: steps(t, "slow"),
+			want: want{res: &fnapi.RunFunctionResponse{
+				Desired: &fnapi.State{},
+				Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_FATAL, Message: `server function "slow" timed out after 100ms`}},
+			}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_ = os.Remove(requestPath)
+			res, err := srv.RunFunction(context.Background(), tc.req)
+			if tc.want.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.want.err) {
+					t.Fatalf("Expected error containing %q but got %v", tc.want.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.res, res, protocmp.Transform(), protocmp.IgnoreFields(&fnapi.RunFunctionResponse{}, "meta")); diff != "" {
+				t.Errorf("Response: -want +got\n%s", diff)
+			}
+			if tc.want.request == nil {
+				return
+			}
+			raw, err := os.ReadFile(requestPath) //nolint:gosec // Reading test files is intended.
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isYAML := !strings.HasPrefix(string(raw), "{"); isYAML != tc.want.yaml {
+				t.Errorf("Expected YAML request %t but got:\n%s", tc.want.yaml, raw)
+			}
+			got := &ExecRequest{}
+			if err := sigsyaml.Unmarshal(raw, got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.request, got); diff != "" {
+				t.Errorf("Request: -want +got\n%s", diff)
+			}
+		})
+	}
+}
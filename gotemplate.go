@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// AnnotationKeyTemplateResourceName is the annotation that holds the name of
+// a composed resource rendered by a TemplateFunction. It is removed from the
+// desired resource.
+const AnnotationKeyTemplateResourceName = "server.fn.crossplane.io/resource-name"
+
+// Keys of the template data of a TemplateFunction, in addition to
+// TemplateKeyComposite, TemplateKeyContext and TemplateKeyEnvironment.
+const (
+	TemplateKeyObserved = "observed"
+	TemplateKeyValues   = "values"
+)
+
+// TemplateInput is the input of a TemplateFunction.
+type TemplateInput struct {
+	// Template is the source of a Go template.
+	Template string `json:"template,omitempty"`
+
+	// TemplateRef is the path of a template in the templates of the
+	// TemplateFunction. See TemplateFiles.
+	TemplateRef string `json:"templateRef,omitempty"`
+
+	// Values are passed to the template as .values.
+	Values map[string]any `json:"values,omitempty"`
+}
+
+// A TemplateOption configures a TemplateFunction.
+type TemplateOption func(fn *TemplateFunction)
+
+// TemplateFiles sets the file system templates can be referenced from by
+// templateRef, e.g. an embed.FS.
+func TemplateFiles(fsys fs.FS) TemplateOption {
+	return func(fn *TemplateFunction) {
+		fn.files = fsys
+	}
+}
+
+// TemplateFuncs adds functions to the template functions.
+func TemplateFuncs(funcs template.FuncMap) TemplateOption {
+	return func(fn *TemplateFunction) {
+		for name, f := range funcs {
+			fn.funcs[name] = f
+		}
+	}
+}
+
+// A TemplateFunction is a ServerFunction that renders the multi-document Go
+// template of its input into desired resources.
+//
+// The template data contains the observed composite resource (.xr), the
+// observed composed resources by name (.observed), the context (.context),
+// the environment (.environment) and the values of the input (.values).
+// The sprig functions as well as toYaml and fromYaml are available.
+//
+// Every rendered document must either be the composite resource, identified
+// by its apiVersion and kind, or a composed resource whose name is set by the
+// annotation AnnotationKeyTemplateResourceName.
+type TemplateFunction struct {
+	files fs.FS
+	funcs template.FuncMap
+}
+
+// NewTemplateFunction returns a new TemplateFunction.
+func NewTemplateFunction(opts ...TemplateOption) *TemplateFunction {
+	fn := &TemplateFunction{funcs: sprig.TxtFuncMap()}
+	fn.funcs["toYaml"] = toYAML
+	fn.funcs["fromYaml"] = fromYAML
+	for _, o := range opts {
+		o(fn)
+	}
+	return fn
+}
+
+// NewInput implements InputFactory.
+func (f *TemplateFunction) NewInput() any {
+	return &TemplateInput{}
+}
+
+// RawInput implements RawInputFunction, since its input contains Go templates.
+func (f *TemplateFunction) RawInput() bool {
+	return true
+}
+
+// ValidateInput implements InputValidator.
+func (f *TemplateFunction) ValidateInput(input any) field.ErrorList {
+	in := input.(*TemplateInput) //nolint:forcetypeassert // NewInput returns a *TemplateInput.
+	switch {
+	case in.Template == "" && in.TemplateRef == "":
+		return field.ErrorList{field.Required(field.NewPath("template"), "either template or templateRef is required")}
+	case in.Template != "" && in.TemplateRef != "":
+		return field.ErrorList{field.Forbidden(field.NewPath("templateRef"), "must not be set together with template")}
+	case in.TemplateRef != "" && f.files == nil:
+		return field.ErrorList{field.Forbidden(field.NewPath("templateRef"), "no templates are embedded")}
+	}
+	return nil
+}
+
+// Run implements ServerFunction.
+func (f *TemplateFunction) Run(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	in := &TemplateInput{}
+	if err := req.GetInput(in); err != nil {
+		return errors.Wrap(err, "cannot decode input")
+	}
+
+	name, src := "template", in.Template
+	if in.TemplateRef != "" {
+		raw, err := fs.ReadFile(f.files, in.TemplateRef)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read template %q", in.TemplateRef)
+		}
+		name, src = in.TemplateRef, string(raw)
+	}
+	tpl, err := template.New(name).Funcs(f.funcs).Parse(src)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse template")
+	}
+
+	native := req.GetNativeRequest()
+	data := templateData(native)
+	data[TemplateKeyObserved] = resourceMaps(native.GetObserved().GetResources())
+	data[TemplateKeyValues] = in.Values
+	b := &bytes.Buffer{}
+	if err := tpl.Execute(b, data); err != nil {
+		return errors.Wrap(err, "cannot execute template")
+	}
+
+	xr := native.GetObserved().GetComposite().GetResource().GetFields()
+	xrAPIVersion, xrKind := xr["apiVersion"].GetStringValue(), xr["kind"].GetStringValue()
+	dec := utilyaml.NewYAMLOrJSONDecoder(b, 4096)
+	for i := 0; ; i++ {
+		u := &unstructured.Unstructured{}
+		if err := dec.Decode(&u.Object); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "cannot decode rendered document %d", i)
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+
+		name := u.GetAnnotations()[AnnotationKeyTemplateResourceName]
+		if name == "" && u.GetAPIVersion() == xrAPIVersion && u.GetKind() == xrKind {
+			s, err := structpb.NewStruct(u.Object)
+			if err != nil {
+				return errors.Wrap(err, "cannot convert composite resource to protobuf")
+			}
+			res.SetCompositeRaw(&fnapi.Resource{Resource: s})
+			continue
+		}
+		if name == "" {
+			return errors.Errorf("rendered %s %q has no annotation %q", u.GetKind(), u.GetName(), AnnotationKeyTemplateResourceName)
+		}
+		annotations := u.GetAnnotations()
+		delete(annotations, AnnotationKeyTemplateResourceName)
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		u.SetAnnotations(annotations)
+		s, err := structpb.NewStruct(u.Object)
+		if err != nil {
+			return errors.Wrapf(err, "cannot convert composed resource %q to protobuf", name)
+		}
+		res.SetComposedRaw(name, &fnapi.Resource{Resource: s})
+	}
+}
+
+// toYAML encodes v as YAML without a trailing newline.
+func toYAML(v any) (string, error) {
+	raw, err := sigsyaml.Marshal(v)
+	return strings.TrimSuffix(string(raw), "\n"), err
+}
+
+// fromYAML decodes a YAML document.
+func fromYAML(s string) (any, error) {
+	var v any
+	err := sigsyaml.Unmarshal([]byte(s), &v)
+	return v, err
+}
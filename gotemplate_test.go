@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	fncontext "github.com/crossplane/function-sdk-go/context"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTemplateFunction(t *testing.T) {
+	files := fstest.MapFS{
+		"buckets.yaml": {Data: []byte(`
+{{- range $i, $region := .values.regions }}
+---
+apiVersion: s3.aws.upbound.io/v1beta1
+kind: Bucket
+metadata:
+  name: {{ $.xr.metadata.name }}-{{ $region }}
+  annotations:
+    server.fn.crossplane.io/resource-name: bucket-{{ $i }}
+spec:
+  forProvider:
+    region: {{ $region | quote }}
+    tags: {{ $.environment.tags | toJson }}
+{{- end }}
+---
+apiVersion: example.org/v1
+kind: XR
+status:
+  buckets: {{ len .values.regions }}
+`)},
+		"templates/config.yaml": {Data: []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    server.fn.crossplane.io/resource-name: config
+data:
+  name: {{ .xr.metadata.name }}
+`)},
+	}
+	// Templates must not be rendered by the InputTemplater of the server.
+	srv := NewServer(WithInputTemplating(GoTemplater), WithFunction("template", NewTemplateFunction(TemplateFiles(files))))
+
+	request := func(t *testing.T, input map[string]any) *fnapi.RunFunctionRequest {
+		t.Helper()
+		req := newTestRequest(t, "template", input)
+		req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+			"apiVersion": "example.org/v1",
+			"kind":       "XR",
+			"metadata":   map[string]any{"name": "example"},
+		})}
+		req.Context = &structpb.Struct{Fields: map[string]*structpb.Value{
+			fncontext.KeyEnvironment: structpb.NewStructValue(mustStruct(t, map[string]any{"tags": map[string]any{"team": "platform"}})),
+		}}
+		return req
+	}
+	bucket := func(name, region string) *fnapi.Resource {
+		return &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+			"apiVersion": "s3.aws.upbound.io/v1beta1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"name": name},
+			"spec": map[string]any{"forProvider": map[string]any{
+				"region": region,
+				"tags":   map[string]any{"team": "platform"},
+			}},
+		})}
+	}
+
+	cases := map[string]struct {
+		input map[string]any
+		want  *fnapi.State
+		err   bool
+	}{
+		"TemplateRef": {
+			input: map[string]any{"templateRef": "buckets.yaml", "values": map[string]any{"regions": []any{"eu-central-1", "us-east-1"}}},
+			want: &fnapi.State{
+				Composite: &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+					"apiVersion": "example.org/v1",
+					"kind":       "XR",
+					"status":     map[string]any{"buckets": 2},
+				})},
+				Resources: map[string]*fnapi.Resource{
+					"bucket-0": bucket("example-eu-central-1", "eu-central-1"),
+					"bucket-1": bucket("example-us-east-1", "us-east-1"),
+				},
+			},
+		},
+		"NestedTemplateRef": {
+			input: map[string]any{"templateRef": "templates/config.yaml"},
+			want: &fnapi.State{Resources: map[string]*fnapi.Resource{
+				"config": {Resource: mustStruct(t, map[string]any{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]any{},
+					"data":       map[string]any{"name": "example"},
+				})},
+			}},
+		},
+		"MissingTemplateRef": {
+			input: map[string]any{"templateRef": "templates/missing.yaml"},
+			err:   true,
+		},
+		"InlineTemplate": {
+			input: map[string]any{"template": `
+{{- $cfg := "size: 3" | fromYaml }}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    server.fn.crossplane.io/resource-name: config
+data:
+  size: {{ $cfg.size | toString | quote }}
+  name: {{ .xr.metadata.name | upper }}
+`},
+			want: &fnapi.State{Resources: map[string]*fnapi.Resource{
+				"config": {Resource: mustStruct(t, map[string]any{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]any{},
+					"data":       map[string]any{"size": "3", "name": "EXAMPLE"},
+				})},
+			}},
+		},
+		"MissingName": {
+			input: map[string]any{"template": "apiVersion: v1\nkind: ConfigMap\n"},
+			err:   true,
+		},
+		"InvalidTemplate": {
+			input: map[string]any{"template": "{{ .xr"},
+			err:   true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := srv.RunFunction(context.Background(), request(t, tc.input))
+			if tc.err != (err != nil) {
+				t.Fatalf("Expected error %t but got %v", tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			if diff := cmp.Diff(tc.want, res.GetDesired(), protocmp.Transform()); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
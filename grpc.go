@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/crossplane/function-sdk-go"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// DefaultHealthCheckInterval is the default interval in which the readiness
+// of ServerFunctions is checked.
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// A ReadyChecker is a ServerFunction that reports whether it is ready to
+// serve requests, e.g. because it depends on an external system.
+//
+// Ready returns an error if the ServerFunction is not ready.
+type ReadyChecker interface {
+	Ready(ctx context.Context) error
+}
+
+// ServeOptions configure how a Server is served.
+type ServeOptions struct {
+	function.ServeOptions
+
+	// Reflection enables gRPC server reflection.
+	Reflection bool
+
+	// HealthCheckInterval is the interval in which the readiness of
+	// ServerFunctions is checked. DefaultHealthCheckInterval is used if it
+	// is not positive.
+	HealthCheckInterval time.Duration
+}
+
+// A ServeOption configures how a Server is served.
+type ServeOption func(o *ServeOptions) error
+
+// SDKOptions applies ServeOptions of the function SDK, like function.Listen,
+// function.MTLSCertificates and function.Insecure.
+func SDKOptions(opts ...function.ServeOption) ServeOption {
+	return func(o *ServeOptions) error {
+		for _, fn := range opts {
+			if err := fn(&o.ServeOptions); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Reflection enables or disables gRPC server reflection.
+func Reflection(enabled bool) ServeOption {
+	return func(o *ServeOptions) error {
+		o.Reflection = enabled
+		return nil
+	}
+}
+
+// HealthCheckInterval sets the interval in which the readiness of
+// ServerFunctions is checked. The interval must be positive.
+func HealthCheckInterval(d time.Duration) ServeOption {
+	return func(o *ServeOptions) error {
+		if d <= 0 {
+			return errors.Errorf("health check interval must be positive, got %s", d)
+		}
+		o.HealthCheckInterval = d
+		return nil
+	}
+}
+
+// Serve srv via gRPC. Blocks until the server returns an error.
+//
+// In addition to function.Serve, the standard grpc.health.v1 health service
+// is served. The status of every ServerFunction is reported with its name as
+// service name. ServerFunctions that implement ReadyChecker are NOT_SERVING
+// while they are not ready. The overall status (empty service name) and the
+// status of the FunctionRunnerService are SERVING only if all ServerFunctions
+// are ready.
+func Serve(srv *Server, opts ...ServeOption) error {
+	so := &ServeOptions{
+		ServeOptions: function.ServeOptions{
+			Network: function.DefaultNetwork,
+			Address: function.DefaultAddress,
+		},
+		HealthCheckInterval: DefaultHealthCheckInterval,
+	}
+	for _, fn := range opts {
+		if err := fn(so); err != nil {
+			return errors.Wrap(err, "cannot apply ServeOption")
+		}
+	}
+	if so.Credentials == nil {
+		return errors.New("no credentials provided - did you specify the Insecure or MTLSCertificates options?")
+	}
+
+	lis, err := net.Listen(so.Network, so.Address)
+	if err != nil {
+		return errors.Wrapf(err, "cannot listen for %s connections at address %q", so.Network, so.Address)
+	}
+	return serve(srv, lis, so)
+}
+
+func serve(srv *Server, lis net.Listener, so *ServeOptions) error {
+	gs := grpc.NewServer(grpc.Creds(so.Credentials))
+	if so.Reflection {
+		reflection.Register(gs)
+	}
+	fnapi.RegisterFunctionRunnerServiceServer(gs, srv)
+
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(gs, hs)
+
+	interval := so.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			srv.updateHealth(ctx, hs, interval)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	return errors.Wrap(gs.Serve(lis), "cannot serve mTLS gRPC connections")
+}
+
+// CheckReady checks the readiness of all ServerFunctions that implement
+// ReadyChecker and returns the errors of those that are not ready by name.
+func (s *Server) CheckReady(ctx context.Context) map[string]error {
+	errs := map[string]error{}
+	for name, reg := range s.snapshot() {
+		rc, ok := reg.fn.(ReadyChecker)
+		if reg.disabled || !ok {
+			continue
+		}
+		if err := rc.Ready(ctx); err != nil {
+			errs[name] = err
+		}
+	}
+	return errs
+}
+
+// updateHealth sets the health status of all ServerFunctions and the
+// overall status.
+func (s *Server) updateHealth(ctx context.Context, hs *health.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	errs := s.CheckReady(ctx)
+	overall := healthpb.HealthCheckResponse_SERVING
+	for name, reg := range s.snapshot() {
+		status := healthpb.HealthCheckResponse_SERVING
+		if reg.disabled {
+			// Disabled functions do not affect the overall status.
+			hs.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+			continue
+		}
+		if err, ok := errs[name]; ok {
+			s.log.Info("Server function is not ready", "function", name, "error", err)
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = status
+		}
+		hs.SetServingStatus(name, status)
+	}
+	hs.SetServingStatus("", overall)
+	hs.SetServingStatus(fnapi.FunctionRunnerService_ServiceDesc.ServiceName, overall)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crossplane/function-sdk-go"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// readyFunction is a ServerFunction that is ready if ready is set.
+type readyFunction struct {
+	testFunction
+	ready atomic.Bool
+}
+
+func (f *readyFunction) Ready(_ context.Context) error {
+	if !f.ready.Load() {
+		return errors.New("not ready")
+	}
+	return nil
+}
+
+func TestServe(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	external := &readyFunction{testFunction: noop}
+	srv := NewServer(
+		WithFunction("noop", noop),
+		WithFunction("external", external),
+	)
+
+	lis := bufconn.Listen(1 << 20)
+	so := &ServeOptions{HealthCheckInterval: 10 * time.Millisecond}
+	if err := SDKOptions(function.Insecure(true))(so); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = serve(srv, lis, so)
+	}()
+	defer lis.Close() //nolint:errcheck // Nothing to do if closing fails in tests.
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck // Nothing to do if closing fails in tests.
+
+	hc := healthpb.NewHealthClient(conn)
+	status := func() map[string]healthpb.HealthCheckResponse_ServingStatus {
+		got := map[string]healthpb.HealthCheckResponse_ServingStatus{}
+		for _, svc := range []string{"", fnapi.FunctionRunnerService_ServiceDesc.ServiceName, "noop", "external"} {
+			res, err := hc.Check(context.Background(), &healthpb.HealthCheckRequest{Service: svc})
+			if err != nil {
+				got[svc] = healthpb.HealthCheckResponse_UNKNOWN
+				continue
+			}
+			got[svc] = res.GetStatus()
+		}
+		return got
+	}
+	waitFor := func(want map[string]healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got := status()
+			diff := cmp.Diff(want, got)
+			if diff == "" {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Health: -want +got\n%s", diff)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor(map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"": healthpb.HealthCheckResponse_NOT_SERVING,
+		fnapi.FunctionRunnerService_ServiceDesc.ServiceName: healthpb.HealthCheckResponse_NOT_SERVING,
+		"noop":     healthpb.HealthCheckResponse_SERVING,
+		"external": healthpb.HealthCheckResponse_NOT_SERVING,
+	})
+
+	external.ready.Store(true)
+	waitFor(map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"": healthpb.HealthCheckResponse_SERVING,
+		fnapi.FunctionRunnerService_ServiceDesc.ServiceName: healthpb.HealthCheckResponse_SERVING,
+		"noop":     healthpb.HealthCheckResponse_SERVING,
+		"external": healthpb.HealthCheckResponse_SERVING,
+	})
+
+	// The FunctionRunnerService is served.
+	if _, err := fnapi.NewFunctionRunnerServiceClient(conn).RunFunction(context.Background(), newTestRequest(t, "noop", nil)); err != nil {
+		t.Errorf("RunFunction: %v", err)
+	}
+
+	// Reflection is disabled by default.
+	stream, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err == nil {
+		_ = stream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+			MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{},
+		})
+		_, err = stream.Recv()
+	}
+	if err == nil {
+		t.Error("Expected reflection to be disabled")
+	}
+}
+
+func TestHealthCheckInterval(t *testing.T) {
+	cases := map[string]struct {
+		interval time.Duration
+		err      bool
+	}{
+		"Positive": {interval: time.Second},
+		"Zero":     {interval: 0, err: true},
+		"Negative": {interval: -time.Second, err: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := HealthCheckInterval(tc.interval)(&ServeOptions{})
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error %t but got %v", tc.err, err)
+			}
+		})
+	}
+
+	t.Run("ZeroServeOptions", func(t *testing.T) {
+		// A zero interval set directly on ServeOptions falls back to the
+		// default instead of panicking.
+		lis := bufconn.Listen(1 << 20)
+		so := &ServeOptions{}
+		if err := SDKOptions(function.Insecure(true))(so); err != nil {
+			t.Fatal(err)
+		}
+		errs := make(chan error, 1)
+		go func() {
+			errs <- serve(NewServer(), lis, so)
+		}()
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Errorf("Check: %v", err)
+		}
+		lis.Close()
+		<-errs
+	})
+}
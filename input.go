@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/mistermx/crossplane-function-server/apis/v1alpha1"
+	"github.com/mistermx/crossplane-function-server/apis/v1beta1"
+)
+
+// parseServerInput parses the native function input into a ServerInput.
+// Inputs of older API versions are converted to v1beta1. Inputs of any other
+// apiVersion are parsed as v1alpha1, like before v1beta1 existed.
+func parseServerInput(in *structpb.Struct) (*v1beta1.ServerInput, error) {
+	serverInput := &v1beta1.ServerInput{}
+	if in.GetFields()["apiVersion"].GetStringValue() == v1beta1.GroupVersion.String() {
+		if err := resource.AsObject(in, serverInput); err != nil {
+			return nil, err
+		}
+		return serverInput, validateServerInput(serverInput)
+	}
+	src := &v1alpha1.ServerInput{}
+	if err := resource.AsObject(in, src); err != nil {
+		return nil, err
+	}
+	return serverInput, errors.Wrap(src.ConvertTo(serverInput), "cannot convert input to v1beta1")
+}
+
+// validateServerInput rejects a ServerInput that sets steps together with the
+// fields of a single call, which would be ignored.
+func validateServerInput(in *v1beta1.ServerInput) error {
+	if len(in.Spec.Steps) == 0 {
+		return nil
+	}
+	spec := field.NewPath("spec")
+	errs := field.ErrorList{}
+	if in.Spec.FunctionName != "" {
+		errs = append(errs, field.Forbidden(spec.Child("functionName"), "must not be set together with steps"))
+	}
+	if in.Spec.FunctionVersion != "" {
+		errs = append(errs, field.Forbidden(spec.Child("functionVersion"), "must not be set together with steps"))
+	}
+	if in.Spec.Timeout != nil {
+		errs = append(errs, field.Forbidden(spec.Child("timeout"), "must not be set together with steps"))
+	}
+	if len(in.Spec.Input.Raw) > 0 {
+		errs = append(errs, field.Forbidden(spec.Child("input"), "must not be set together with steps"))
+	}
+	if len(errs) > 0 {
+		return errInvalidInput{err: errs.ToAggregate()}
+	}
+	return nil
+}
+
+// errInvalidInput is returned by prepareInput if an InputValidator rejects
+// the input of a ServerFunction.
+type errInvalidInput struct {
+	err error
+}
+
+func (e errInvalidInput) Error() string {
+	return "invalid input: " + e.err.Error()
+}
+
+func (e errInvalidInput) Unwrap() error {
+	return e.err
+}
+
+// IsErrorInvalidInput returns true if the error was caused by an input that
+// was rejected by an InputValidator.
+func IsErrorInvalidInput(err error) bool {
+	return errors.As(err, &errInvalidInput{})
+}
+
+// prepareInput applies defaults and validation to the raw input of fn if it
+// implements InputDefaulter or InputValidator. It returns the possibly
+// defaulted raw input.
+func prepareInput(fn ServerFunction, raw []byte) ([]byte, error) {
+	defaulter, isDefaulter := fn.(InputDefaulter)
+	validator, isValidator := fn.(InputValidator)
+	if !isDefaulter && !isValidator {
+		return raw, nil
+	}
+
+	input, err := newInput(fn, raw)
+	if err != nil {
+		return nil, err
+	}
+	if isDefaulter {
+		defaulter.Default(input)
+	}
+	if isValidator {
+		if errs := validator.ValidateInput(input); len(errs) > 0 {
+			return nil, errInvalidInput{err: errs.ToAggregate()}
+		}
+	}
+	if !isDefaulter {
+		return raw, nil
+	}
+	defaulted, err := json.Marshal(input)
+	return defaulted, errors.Wrap(err, "cannot marshal defaulted input")
+}
+
+func newInput(fn ServerFunction, raw []byte) (any, error) {
+	if f, ok := fn.(InputFactory); ok {
+		input := f.NewInput()
+		if len(raw) == 0 {
+			return input, nil
+		}
+		return input, errors.Wrap(json.Unmarshal(raw, input), "cannot unmarshal input")
+	}
+	input := map[string]any{}
+	if len(raw) == 0 {
+		return input, nil
+	}
+	return input, errors.Wrap(json.Unmarshal(raw, &input), "cannot unmarshal input")
+}
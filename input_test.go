@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type validatedInput struct {
+	Name     string `json:"name"`
+	Replicas int    `json:"replicas"`
+}
+
+type validatedFunction struct {
+	got validatedInput
+}
+
+func (f *validatedFunction) NewInput() any {
+	return &validatedInput{}
+}
+
+func (f *validatedFunction) Default(input any) {
+	in := input.(*validatedInput)
+	if in.Replicas == 0 {
+		in.Replicas = 1
+	}
+}
+
+func (f *validatedFunction) ValidateInput(input any) field.ErrorList {
+	in := input.(*validatedInput)
+	errs := field.ErrorList{}
+	if in.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("name"), "name is required"))
+	}
+	if in.Replicas > 5 {
+		errs = append(errs, field.Invalid(field.NewPath("replicas"), in.Replicas, "must not exceed 5"))
+	}
+	return errs
+}
+
+func (f *validatedFunction) Run(_ context.Context, req ServerFunctionRequest, _ ServerFunctionResponse) error {
+	return req.GetInput(&f.got)
+}
+
+func TestInputDefaultingAndValidation(t *testing.T) {
+	type want struct {
+		input   validatedInput
+		results []*fnapi.Result
+	}
+	cases := map[string]struct {
+		input map[string]any
+		want
+	}{
+		"Defaulted": {
+			input: map[string]any{"name": "example"},
+			want: want{
+				input: validatedInput{Name: "example", Replicas: 1},
+			},
+		},
+		"Invalid": {
+			input: map[string]any{"replicas": 7},
+			want: want{
+				results: []*fnapi.Result{
+					{
+						Severity: fnapi.Severity_SEVERITY_FATAL,
+						Message:  `cannot prepare input of subroutine function "validated": invalid input: [name: Required value: name is required, replicas: Invalid value: 7: must not exceed 5]`,
+					},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fn := &validatedFunction{}
+			srv := NewServer(WithFunction("validated", fn))
+			res, err := srv.RunFunction(context.Background(), newTestRequest(t, "validated", tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.input, fn.got); diff != "" {
+				t.Errorf("Input: -want +got\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.results, res.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("Results: -want +got\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/uuid"
+)
+
+type loggerKey struct{}
+
+// WithLogger sets the logger of a Server.
+//
+// Every ServerFunction receives a copy of this logger through its context
+// that is tagged with the function name, the composite resource and a request
+// ID. Use LoggerFrom to retrieve it.
+func WithLogger(log logging.Logger) ServerOption {
+	return func(server *Server) {
+		server.log = log
+	}
+}
+
+// ContextWithLogger returns a copy of ctx that carries the given logger.
+func ContextWithLogger(ctx context.Context, log logging.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// LoggerFrom returns the logger that is carried by ctx.
+//
+// If ctx carries no logger, a logger that does nothing is returned.
+func LoggerFrom(ctx context.Context) logging.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(logging.Logger); ok {
+		return log
+	}
+	return logging.NewNopLogger()
+}
+
+// requestLogger returns a logger that is tagged with the composite resource
+// of the request and a new request ID.
+func requestLogger(log logging.Logger, req *fnapi.RunFunctionRequest) logging.Logger {
+	xr := req.GetObserved().GetComposite().GetResource().GetFields()
+	return log.WithValues(
+		"request-id", uuid.NewString(),
+		"xr-apiversion", xr["apiVersion"].GetStringValue(),
+		"xr-kind", xr["kind"].GetStringValue(),
+		"xr-name", xr["metadata"].GetStructValue().GetFields()["name"].GetStringValue(),
+	)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+)
+
+// valuesLogger is a logging.Logger that records its structured values.
+type valuesLogger struct {
+	values []any
+}
+
+func (l *valuesLogger) Info(_ string, _ ...any)  {}
+func (l *valuesLogger) Debug(_ string, _ ...any) {}
+func (l *valuesLogger) WithValues(keysAndValues ...any) logging.Logger {
+	return &valuesLogger{values: append(append([]any{}, l.values...), keysAndValues...)}
+}
+
+func TestLoggerFrom(t *testing.T) {
+	var got []any
+	fn := testFunction(func(ctx context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		got = LoggerFrom(ctx).(*valuesLogger).values
+		return nil
+	})
+	srv := NewServer(
+		WithLogger(&valuesLogger{}),
+		WithFunction("log", fn),
+	)
+
+	req := newTestRequest(t, "log", nil)
+	req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+		"apiVersion": "example.com/v1alpha1",
+		"kind":       "Example",
+		"metadata":   map[string]any{"name": "example"},
+	})}
+	if _, err := srv.RunFunction(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 10 || got[0] != "request-id" || got[1] == "" {
+		t.Fatalf("Expected logger tagged with request ID but got %v", got)
+	}
+	want := []any{
+		"xr-apiversion", "example.com/v1alpha1",
+		"xr-kind", "Example",
+		"xr-name", "example",
+		"function", "log",
+	}
+	if diff := cmp.Diff(want, got[2:]); diff != "" {
+		t.Errorf("-want +got\n%s", diff)
+	}
+}
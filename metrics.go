@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "function_server"
+
+// Error classes of failed ServerFunction invocations.
+const (
+	ErrorClassNotFound     = "not_found"
+	ErrorClassInvalidInput = "invalid_input"
+	ErrorClassTimeout      = "timeout"
+	ErrorClassSaturated    = "saturated"
+	ErrorClassDisabled     = "disabled"
+	ErrorClassDenied       = "denied"
+	ErrorClassConflict     = "conflict"
+	ErrorClassCanceled     = "canceled"
+	ErrorClassFunction     = "function"
+)
+
+// MetricsLabelUnknownFunction is the function label of invocations of names
+// that are neither a registered ServerFunction nor an alias.
+const MetricsLabelUnknownFunction = "<unknown>"
+
+// Metrics records telemetry of ServerFunction invocations.
+//
+// Metrics implements prometheus.Collector and must be registered at a
+// prometheus.Registerer to be exposed.
+type Metrics struct {
+	invocations      *prometheus.CounterVec
+	duration         *prometheus.HistogramVec
+	errors           *prometheus.CounterVec
+	desiredResources *prometheus.HistogramVec
+	cacheHits        *prometheus.CounterVec
+	cacheMisses      *prometheus.CounterVec
+	deprecated       *prometheus.CounterVec
+
+	inFlight *prometheus.Desc
+	queued   *prometheus.Desc
+	rejected *prometheus.Desc
+
+	server *Server
+}
+
+// NewMetrics creates a new, unregistered set of ServerFunction metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		invocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invocations_total",
+			Help:      "Total number of server function invocations.",
+		}, []string{"function"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "invocation_duration_seconds",
+			Help:      "Duration of server function invocations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"function"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "errors_total",
+			Help:      "Total number of failed server function invocations by error class.",
+		}, []string{"function", "class"}),
+		desiredResources: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "desired_resources",
+			Help:      "Number of desired composed resources after a server function invocation.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 8),
+		}, []string{"function"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_hits_total",
+			Help:      "Total number of server function invocations served from the response cache.",
+		}, []string{"function"}),
+		cacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_misses_total",
+			Help:      "Total number of server function invocations not found in the response cache.",
+		}, []string{"function"}),
+		deprecated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "deprecated_invocations_total",
+			Help:      "Total number of server function invocations with a deprecated name.",
+		}, []string{"function", "name"}),
+		inFlight: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "inflight_invocations"),
+			"Number of running invocations of server functions with concurrency limits.",
+			[]string{"function"}, nil,
+		),
+		queued: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "queued_invocations"),
+			"Number of queued invocations of server functions with concurrency limits.",
+			[]string{"function"}, nil,
+		),
+		rejected: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "rejected_invocations_total"),
+			"Total number of invocations rejected due to concurrency limits.",
+			[]string{"function"}, nil,
+		),
+	}
+}
+
+// WithMetrics records telemetry of all ServerFunction invocations of a
+// Server in the given Metrics.
+func WithMetrics(m *Metrics) ServerOption {
+	return func(server *Server) {
+		m.server = server
+		server.metrics = m
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.invocations.Describe(ch)
+	m.duration.Describe(ch)
+	m.errors.Describe(ch)
+	m.desiredResources.Describe(ch)
+	m.cacheHits.Describe(ch)
+	m.cacheMisses.Describe(ch)
+	m.deprecated.Describe(ch)
+	ch <- m.inFlight
+	ch <- m.queued
+	ch <- m.rejected
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.invocations.Collect(ch)
+	m.duration.Collect(ch)
+	m.errors.Collect(ch)
+	m.desiredResources.Collect(ch)
+	m.cacheHits.Collect(ch)
+	m.cacheMisses.Collect(ch)
+	m.deprecated.Collect(ch)
+	if m.server == nil {
+		return
+	}
+	for name, stats := range m.server.Stats() {
+		ch <- prometheus.MustNewConstMetric(m.inFlight, prometheus.GaugeValue, float64(stats.InFlight), name)
+		ch <- prometheus.MustNewConstMetric(m.queued, prometheus.GaugeValue, float64(stats.Queued), name)
+		ch <- prometheus.MustNewConstMetric(m.rejected, prometheus.CounterValue, float64(stats.Rejected), name)
+	}
+}
+
+// observe records a single invocation of the named ServerFunction.
+func (m *Metrics) observe(name string, start time.Time, res *RunServerFunctionResponse, err error) {
+	if m == nil {
+		return
+	}
+	m.invocations.WithLabelValues(name).Inc()
+	m.duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(name, ErrorClass(err)).Inc()
+		return
+	}
+	m.desiredResources.WithLabelValues(name).Observe(float64(len(res.DesiredComposed)))
+}
+
+// observeCache records a response cache lookup of the named ServerFunction.
+func (m *Metrics) observeCache(name string, hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.cacheHits.WithLabelValues(name).Inc()
+		return
+	}
+	m.cacheMisses.WithLabelValues(name).Inc()
+}
+
+// observeDeprecated records an invocation of the named ServerFunction with
+// the deprecated name it was called with.
+func (m *Metrics) observeDeprecated(function, name string) {
+	if m == nil {
+		return
+	}
+	m.deprecated.WithLabelValues(function, name).Inc()
+}
+
+// ErrorClass returns the class of an error returned by a ServerFunction
+// invocation.
+func ErrorClass(err error) string {
+	switch {
+	case IsErrorNotFound(err):
+		return ErrorClassNotFound
+	case IsErrorInvalidInput(err):
+		return ErrorClassInvalidInput
+	case IsErrorTimeout(err):
+		return ErrorClassTimeout
+	case IsErrorSaturated(err):
+		return ErrorClassSaturated
+	case IsErrorDisabled(err):
+		return ErrorClassDisabled
+	case IsErrorDenied(err):
+		return ErrorClassDenied
+	case IsErrorConflict(err):
+		return ErrorClassConflict
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassCanceled
+	default:
+		return ErrorClassFunction
+	}
+}
+
+// ServeMetrics exposes the metrics of the given prometheus.Gatherer on the
+// /metrics path of an HTTP server listening on address. Blocks until the
+// server returns an error.
+func ServeMetrics(address string, g prometheus.Gatherer) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(g, promhttp.HandlerOpts{}))
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return errors.Wrap(srv.ListenAndServe(), "cannot serve metrics")
+}
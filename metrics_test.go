@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	okFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		res.SetComposedRaw("a", nil)
+		res.SetComposedRaw("b", nil)
+		return nil
+	})
+	failFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return errors.New("boom")
+	})
+
+	m := NewMetrics()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+	srv := NewServer(
+		WithMetrics(m),
+		WithFunction("ok", okFunction, WithMaxConcurrency(1, 0)),
+		WithFunction("fail", failFunction),
+	)
+
+	for _, name := range []string{"ok", "ok", "fail", "missing"} {
+		_, _ = srv.RunFunction(context.Background(), newTestRequest(t, name, nil))
+	}
+
+	want := `
+# HELP function_server_errors_total Total number of failed server function invocations by error class.
+# TYPE function_server_errors_total counter
+function_server_errors_total{class="function",function="fail"} 1
+function_server_errors_total{class="not_found",function="<unknown>"} 1
+# HELP function_server_invocations_total Total number of server function invocations.
+# TYPE function_server_invocations_total counter
+function_server_invocations_total{function="fail"} 1
+function_server_invocations_total{function="<unknown>"} 1
+function_server_invocations_total{function="ok"} 2
+# HELP function_server_queued_invocations Number of queued invocations of server functions with concurrency limits.
+# TYPE function_server_queued_invocations gauge
+function_server_queued_invocations{function="ok"} 0
+`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"function_server_errors_total",
+		"function_server_invocations_total",
+		"function_server_queued_invocations",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(m, "function_server_desired_resources"); n != 1 {
+		t.Errorf("Expected 1 desired resources histogram but got %d", n)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// A ProxyOption configures a ProxyFunction.
+type ProxyOption func(o *proxyOptions) error
+
+type proxyOptions struct {
+	creds     credentials.TransportCredentials
+	dialOpts  []grpc.DialOption
+	inputKind *schema.GroupVersionKind
+}
+
+// ProxyInsecure connects to the remote function without TLS. This is only
+// useful for testing and development.
+func ProxyInsecure() ProxyOption {
+	return func(o *proxyOptions) error {
+		o.creds = insecure.NewCredentials()
+		return nil
+	}
+}
+
+// ProxyMTLSCertificates connects to the remote function via mTLS using the
+// client certificate (tls.crt, tls.key) and the CA used to verify the server
+// (ca.crt) in the given directory, like Crossplane does.
+func ProxyMTLSCertificates(dir string) ProxyOption {
+	return func(o *proxyOptions) error {
+		crt, err := tls.LoadX509KeyPair(
+			filepath.Clean(filepath.Join(dir, "tls.crt")),
+			filepath.Clean(filepath.Join(dir, "tls.key")),
+		)
+		if err != nil {
+			return errors.Wrap(err, "cannot load X509 keypair")
+		}
+		ca, err := os.ReadFile(filepath.Clean(filepath.Join(dir, "ca.crt")))
+		if err != nil {
+			return errors.Wrap(err, "cannot read CA certificate")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return errors.New("invalid CA certificate")
+		}
+		o.creds = credentials.NewTLS(&tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{crt},
+			RootCAs:      pool,
+		})
+		return nil
+	}
+}
+
+// ProxyDialOptions adds gRPC dial options used to connect to the remote
+// function.
+func ProxyDialOptions(opts ...grpc.DialOption) ProxyOption {
+	return func(o *proxyOptions) error {
+		o.dialOpts = append(o.dialOpts, opts...)
+		return nil
+	}
+}
+
+// ProxyInputKind sets the apiVersion and kind of the input that is sent to
+// the remote function, so Compositions only need to specify its fields.
+func ProxyInputKind(gvk schema.GroupVersionKind) ProxyOption {
+	return func(o *proxyOptions) error {
+		o.inputKind = &gvk
+		return nil
+	}
+}
+
+// A ProxyFunction is a ServerFunction that forwards calls to a remote
+// Crossplane function via its FunctionRunnerService.
+//
+// The input of the ServerFunction is sent as native input of the remote
+// function. The desired state of previous steps is forwarded and replaced
+// by the desired state the remote function returns.
+type ProxyFunction struct {
+	address   string
+	conn      *grpc.ClientConn
+	client    fnapi.FunctionRunnerServiceClient
+	inputKind *schema.GroupVersionKind
+}
+
+// NewProxyFunction returns a ProxyFunction that forwards calls to the
+// function at address. Either ProxyInsecure or ProxyMTLSCertificates must be
+// set.
+//
+// The connection is established lazily. Call Close to release it.
+func NewProxyFunction(address string, opts ...ProxyOption) (*ProxyFunction, error) {
+	o := &proxyOptions{}
+	for _, fn := range opts {
+		if err := fn(o); err != nil {
+			return nil, errors.Wrap(err, "cannot apply ProxyOption")
+		}
+	}
+	if o.creds == nil {
+		return nil, errors.New("no credentials provided - did you specify the ProxyInsecure or ProxyMTLSCertificates options?")
+	}
+	conn, err := grpc.Dial(address, append([]grpc.DialOption{grpc.WithTransportCredentials(o.creds)}, o.dialOpts...)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot dial remote function at %q", address)
+	}
+	return &ProxyFunction{
+		address:   address,
+		conn:      conn,
+		client:    fnapi.NewFunctionRunnerServiceClient(conn),
+		inputKind: o.inputKind,
+	}, nil
+}
+
+// Close the connection to the remote function.
+func (p *ProxyFunction) Close() error {
+	return p.conn.Close()
+}
+
+// Ready implements ReadyChecker. The remote function is not ready while the
+// connection to it fails.
+func (p *ProxyFunction) Ready(_ context.Context) error {
+	p.conn.Connect()
+	if s := p.conn.GetState(); s == connectivity.TransientFailure || s == connectivity.Shutdown {
+		return errors.Errorf("connection to remote function at %q is %s", p.address, s)
+	}
+	return nil
+}
+
+// Run implements ServerFunction.
+func (p *ProxyFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	input := map[string]any{}
+	if err := req.GetInput(&input); err != nil {
+		return errors.Wrap(err, "cannot decode input")
+	}
+	if p.inputKind != nil {
+		input["apiVersion"], input["kind"] = p.inputKind.ToAPIVersionAndKind()
+	}
+	nativeInput, err := structpb.NewStruct(input)
+	if err != nil {
+		return errors.Wrap(err, "cannot convert input to protobuf")
+	}
+
+	native := req.GetNativeRequest()
+	remoteReq := &fnapi.RunFunctionRequest{
+		Meta:     native.GetMeta(),
+		Observed: native.GetObserved(),
+		Desired:  native.GetDesired(),
+		Context:  native.GetContext(),
+		Input:    nativeInput,
+	}
+	if r, ok := res.(*RunServerFunctionResponse); ok {
+		remoteReq.Desired, remoteReq.Context = r.desiredState(native)
+	}
+
+	remoteRes, err := p.client.RunFunction(ctx, remoteReq)
+	if err != nil {
+		return errors.Wrapf(err, "cannot call remote function at %q", p.address)
+	}
+
+	if r, ok := res.(*RunServerFunctionResponse); ok {
+		r.setDesiredState(remoteRes)
+		return nil
+	}
+	if c := remoteRes.GetDesired().GetComposite(); c != nil {
+		res.SetCompositeRaw(c)
+	}
+	for name, r := range remoteRes.GetDesired().GetResources() {
+		res.SetComposedRaw(name, r)
+	}
+	for k, v := range remoteRes.GetContext().GetFields() {
+		if err := res.SetContextField(k, v.AsInterface()); err != nil {
+			return err
+		}
+	}
+	res.SetNativeResults(remoteRes.GetResults())
+	return nil
+}
+
+// desiredState returns the desired state and context of req with the
+// changes of this response applied.
+func (r *RunServerFunctionResponse) desiredState(req *fnapi.RunFunctionRequest) (*fnapi.State, *structpb.Struct) {
+	desired := &fnapi.State{}
+	if req.GetDesired() != nil {
+		desired = proto.Clone(req.GetDesired()).(*fnapi.State)
+	}
+	if r.DesiredComposite != nil {
+		desired.Composite = r.DesiredComposite
+	}
+	if r.DesiredComposed != nil {
+		desired.Resources = r.DesiredComposed
+	}
+	ctx := req.GetContext()
+	if r.DesiredContext != nil {
+		ctx = r.DesiredContext
+	}
+	return desired, ctx
+}
+
+// setDesiredState replaces the desired state, context and results of this
+// response with those of a native response.
+func (r *RunServerFunctionResponse) setDesiredState(res *fnapi.RunFunctionResponse) {
+	r.DesiredComposite = res.GetDesired().GetComposite()
+	r.DesiredComposed = res.GetDesired().GetResources()
+	if r.DesiredComposed == nil {
+		r.DesiredComposed = map[string]*fnapi.Resource{}
+	}
+	r.DesiredContext = res.GetContext()
+	r.Results = res.GetResults()
+}
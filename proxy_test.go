@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// remoteFunction is a native Crossplane function that adds a composed
+// resource named after its input and returns its input as context.
+type remoteFunction struct {
+	fnapi.UnimplementedFunctionRunnerServiceServer
+}
+
+func (f *remoteFunction) RunFunction(_ context.Context, req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+	name := req.GetInput().GetFields()["name"].GetStringValue()
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	desired := req.GetDesired()
+	if desired.Resources == nil {
+		desired.Resources = map[string]*fnapi.Resource{}
+	}
+	desired.Resources[name] = &fnapi.Resource{}
+	return &fnapi.RunFunctionResponse{
+		Desired: desired,
+		Context: &structpb.Struct{Fields: map[string]*structpb.Value{
+			"input": structpb.NewStructValue(req.GetInput()),
+		}},
+		Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_NORMAL, Message: "remote " + name}},
+	}, nil
+}
+
+func TestProxyFunction(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	fnapi.RegisterFunctionRunnerServiceServer(gs, &remoteFunction{})
+	go func() {
+		_ = gs.Serve(lis)
+	}()
+	defer gs.Stop()
+
+	proxy, err := NewProxyFunction("bufnet",
+		ProxyInsecure(),
+		ProxyDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) })),
+		ProxyInputKind(schema.GroupVersionKind{Group: "remote.fn.crossplane.io", Version: "v1", Kind: "Input"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close() //nolint:errcheck // Nothing to do if closing fails in tests.
+
+	local := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		res.SetComposedRaw("local", &fnapi.Resource{})
+		return nil
+	})
+	srv := NewServer(WithFunction("remote", proxy), WithFunction("local", local))
+
+	if err := proxy.Ready(context.Background()); err != nil {
+		t.Errorf("Ready: %v", err)
+	}
+
+	t.Run("Steps", func(t *testing.T) {
+		req := newTestRequest(t, "", nil)
+		req.Input = mustStruct(t, map[string]any{
+			"apiVersion": "server.fn.crossplane.io/v1beta1",
+			"kind":       "ServerInput",
+			"spec": map[string]any{
+				"steps": []any{
+					map[string]any{"functionName": "local"},
+					map[string]any{"functionName": "remote", "input": map[string]any{"name": "proxied"}},
+				},
+			},
+		})
+		res, err := srv.RunFunction(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &fnapi.RunFunctionResponse{
+			Desired: &fnapi.State{Resources: map[string]*fnapi.Resource{
+				"local":   {},
+				"proxied": {},
+			}},
+			Context: mustStruct(t, map[string]any{
+				"input": map[string]any{
+					"apiVersion": "remote.fn.crossplane.io/v1",
+					"kind":       "Input",
+					"name":       "proxied",
+				},
+			}),
+			Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_NORMAL, Message: "remote proxied"}},
+		}
+		if diff := cmp.Diff(want, res, protocmp.Transform()); diff != "" {
+			t.Errorf("-want +got\n%s", diff)
+		}
+	})
+
+	t.Run("RemoteError", func(t *testing.T) {
+		if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "remote", nil)); err == nil {
+			t.Error("Expected error of remote function to be returned")
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Redacted replaces secret values in recordings.
+const Redacted = "REDACTED"
+
+// WithRecording records every RunFunction call of a Server to a file in dir.
+//
+// Connection details and the data of Secrets are redacted. Recordings can
+// be replayed using Replay.
+func WithRecording(dir string) ServerOption {
+	return func(server *Server) {
+		server.recordDir = dir
+	}
+}
+
+// A Recording is a recorded RunFunction call.
+type Recording struct {
+	// Time at which the call was recorded.
+	Time time.Time
+
+	// Request that was received.
+	Request *fnapi.RunFunctionRequest
+
+	// Response that was returned. Nil if the call returned an error.
+	Response *fnapi.RunFunctionResponse
+
+	// Error returned by the call, if any.
+	Error string
+}
+
+type recordingJSON struct {
+	Time     time.Time       `json:"time"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes a Recording as JSON.
+func (r *Recording) MarshalJSON() ([]byte, error) {
+	out := recordingJSON{Time: r.Time, Error: r.Error}
+	var err error
+	if out.Request, err = protojson.Marshal(r.Request); err != nil {
+		return nil, errors.Wrap(err, "cannot marshal request")
+	}
+	if r.Response != nil {
+		if out.Response, err = protojson.Marshal(r.Response); err != nil {
+			return nil, errors.Wrap(err, "cannot marshal response")
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a Recording from JSON.
+func (r *Recording) UnmarshalJSON(data []byte) error {
+	in := recordingJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	r.Time, r.Error = in.Time, in.Error
+	r.Request = &fnapi.RunFunctionRequest{}
+	if err := protojson.Unmarshal(in.Request, r.Request); err != nil {
+		return errors.Wrap(err, "cannot unmarshal request")
+	}
+	r.Response = nil
+	if len(in.Response) > 0 {
+		r.Response = &fnapi.RunFunctionResponse{}
+		if err := protojson.Unmarshal(in.Response, r.Response); err != nil {
+			return errors.Wrap(err, "cannot unmarshal response")
+		}
+	}
+	return nil
+}
+
+// LoadRecordings loads a recording file or all recording files (*.json) in a
+// directory, ordered by their file name.
+func LoadRecordings(path string) ([]*Recording, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, errors.Wrapf(err, "cannot read %q", path)
+	} else if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, errors.Wrapf(err, "cannot list recordings in %q", path)
+		}
+		sort.Strings(files)
+	}
+	recs := make([]*Recording, len(files))
+	for i, f := range files {
+		raw, err := os.ReadFile(f) //nolint:gosec // Reading user supplied files is intended.
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read %q", f)
+		}
+		recs[i] = &Recording{}
+		if err := json.Unmarshal(raw, recs[i]); err != nil {
+			return nil, errors.Wrapf(err, "cannot decode recording %q", f)
+		}
+	}
+	return recs, nil
+}
+
+// record writes a Recording of a RunFunction call to the record directory of
+// the Server. The request must already be redacted.
+func (s *Server) record(req *fnapi.RunFunctionRequest, res *fnapi.RunFunctionResponse, callErr error) error {
+	rec := &Recording{
+		Time:     time.Now().UTC(),
+		Request:  req,
+		Response: redactResponse(res),
+	}
+	if callErr != nil {
+		rec.Error = callErr.Error()
+	}
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal recording")
+	}
+	if err := os.MkdirAll(s.recordDir, 0o700); err != nil {
+		return errors.Wrap(err, "cannot create recording directory")
+	}
+	name := fmt.Sprintf("%s-%s.json", rec.Time.Format("20060102T150405.000000000Z"), uuid.NewString())
+	return errors.Wrap(os.WriteFile(filepath.Join(s.recordDir, name), raw, 0o600), "cannot write recording")
+}
+
+// redactRequest returns a copy of req with all secret values redacted.
+func redactRequest(req *fnapi.RunFunctionRequest) *fnapi.RunFunctionRequest {
+	if req == nil {
+		return nil
+	}
+	req = proto.Clone(req).(*fnapi.RunFunctionRequest)
+	redactState(req.GetObserved())
+	redactState(req.GetDesired())
+	return req
+}
+
+// redactResponse returns a copy of res with all secret values redacted.
+func redactResponse(res *fnapi.RunFunctionResponse) *fnapi.RunFunctionResponse {
+	if res == nil {
+		return nil
+	}
+	res = proto.Clone(res).(*fnapi.RunFunctionResponse)
+	redactState(res.GetDesired())
+	return res
+}
+
+func redactState(state *fnapi.State) {
+	redactResource(state.GetComposite())
+	for _, r := range state.GetResources() {
+		redactResource(r)
+	}
+}
+
+// redactResource redacts the connection details of a resource and the data
+// of a Secret.
+func redactResource(r *fnapi.Resource) {
+	if r == nil {
+		return
+	}
+	for k := range r.ConnectionDetails {
+		r.ConnectionDetails[k] = []byte(Redacted)
+	}
+	fields := r.GetResource().GetFields()
+	if fields["apiVersion"].GetStringValue() != "v1" || fields["kind"].GetStringValue() != "Secret" {
+		return
+	}
+	for _, key := range []string{"data", "stringData"} {
+		for k := range fields[key].GetStructValue().GetFields() {
+			fields[key].GetStructValue().Fields[k] = structpb.NewStringValue(Redacted)
+		}
+	}
+}
+
+// ReplayResult is the outcome of replaying a Recording.
+type ReplayResult struct {
+	// Response returned by the replay. Nil if the replay returned an error.
+	Response *fnapi.RunFunctionResponse
+
+	// Error returned by the replay, if any.
+	Error string
+
+	// Diff between the recorded and the replayed outcome. Empty if both are
+	// equal.
+	Diff string
+}
+
+// Replay feeds a recorded request into fn and diffs the outcome against the
+// recorded one.
+//
+// To replay against a single ServerFunction, wrap it in a Server using
+// NewServer and WithFunction.
+func Replay(ctx context.Context, fn fnapi.FunctionRunnerServiceServer, rec *Recording) *ReplayResult {
+	res, err := fn.RunFunction(ctx, proto.Clone(rec.Request).(*fnapi.RunFunctionRequest))
+	out := &ReplayResult{Response: redactResponse(res)}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	type outcome struct {
+		Response *fnapi.RunFunctionResponse
+		Error    string
+	}
+	out.Diff = cmp.Diff(
+		outcome{Response: rec.Response, Error: rec.Error},
+		outcome{Response: out.Response, Error: out.Error},
+		protocmp.Transform(),
+	)
+	out.Diff = strings.TrimSpace(out.Diff)
+	return out
+}
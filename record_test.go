@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	secret := func() *fnapi.Resource {
+		return &fnapi.Resource{
+			Resource: mustStruct(t, map[string]any{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"data":       map[string]any{"password": "c2VjcmV0"},
+			}),
+			ConnectionDetails: map[string][]byte{"password": []byte("secret")},
+		}
+	}
+	redactedSecret := &fnapi.Resource{
+		Resource: mustStruct(t, map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"data":       map[string]any{"password": Redacted},
+		}),
+		ConnectionDetails: map[string][]byte{"password": []byte(Redacted)},
+	}
+
+	secretFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		res.SetComposedRaw("secret", secret())
+		return nil
+	})
+	dir := t.TempDir()
+	srv := NewServer(WithRecording(dir), WithFunction("secret", secretFunction))
+
+	req := newTestRequest(t, "secret", nil)
+	req.Observed.Resources = map[string]*fnapi.Resource{"secret": secret()}
+	if _, err := srv.RunFunction(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "missing", nil)); err == nil {
+		t.Fatal("Expected error for missing function")
+	}
+
+	recs, err := LoadRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 recordings but got %d", len(recs))
+	}
+	if diff := cmp.Diff(redactedSecret, recs[0].Request.GetObserved().GetResources()["secret"], protocmp.Transform()); diff != "" {
+		t.Errorf("Observed secret: -want +got\n%s", diff)
+	}
+	if diff := cmp.Diff(redactedSecret, recs[0].Response.GetDesired().GetResources()["secret"], protocmp.Transform()); diff != "" {
+		t.Errorf("Desired secret: -want +got\n%s", diff)
+	}
+	if recs[1].Response != nil || recs[1].Error == "" {
+		t.Errorf("Expected recorded error but got response %v and error %q", recs[1].Response, recs[1].Error)
+	}
+
+	// Replaying against the same functions yields no diff.
+	for i, rec := range recs {
+		if res := Replay(context.Background(), srv, rec); res.Diff != "" {
+			t.Errorf("Replay %d: Expected no diff but got\n%s", i, res.Diff)
+		}
+	}
+
+	// Replaying against a changed function yields a diff.
+	changed := NewServer(WithFunction("secret", testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})))
+	if res := Replay(context.Background(), changed, recs[0]); res.Diff == "" {
+		t.Error("Expected diff for changed function")
+	}
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossplane-function-server/apis/v1beta1"
+)
+
+const (
+	// AnnotationKeyCompositionResourceName is the annotation that holds the
+	// name of a composed resource within its Composition.
+	AnnotationKeyCompositionResourceName = "crossplane.io/composition-resource-name"
+
+	// LabelKeyComposite is the label that holds the name of the composite
+	// resource of a composed resource.
+	LabelKeyComposite = "crossplane.io/composite"
+)
+
+// RenderCommand renders a Composition offline by running all of its pipeline
+// steps that target a Server in-process.
+//
+// It can be embedded as a kong command. The Server is expected to be bound
+// via kong.Bind.
+type RenderCommand struct {
+	CompositeResource string `name:"xr" required:"" type:"existingfile" help:"A YAML file specifying the composite resource (XR) to render."`
+	Composition       string `required:"" type:"existingfile" help:"A YAML file specifying the Composition to use to render the XR. Must be mode: Pipeline."`
+	ObservedResources string `short:"o" type:"existingfile" help:"An optional YAML stream of observed composed resources, annotated with their composition resource name."`
+}
+
+// Run renders the Composition and prints the desired resources as YAML.
+func (c *RenderCommand) Run(srv *Server) error {
+	xr, err := readObjects(c.CompositeResource)
+	if err != nil {
+		return err
+	}
+	if len(xr) != 1 {
+		return errors.Errorf("expected exactly one composite resource in %q but got %d", c.CompositeResource, len(xr))
+	}
+	comp, err := readObjects(c.Composition)
+	if err != nil {
+		return err
+	}
+	if len(comp) != 1 {
+		return errors.Errorf("expected exactly one Composition in %q but got %d", c.Composition, len(comp))
+	}
+	var observed []*unstructured.Unstructured
+	if c.ObservedResources != "" {
+		if observed, err = readObjects(c.ObservedResources); err != nil {
+			return err
+		}
+	}
+
+	out, err := Render(context.Background(), srv, xr[0], comp[0], observed...)
+	if err != nil {
+		return err
+	}
+	for _, step := range out.SkippedSteps {
+		fmt.Fprintf(os.Stderr, "Skipping pipeline step %q that does not target a function server\n", step)
+	}
+	return out.WriteYAML(os.Stdout)
+}
+
+// RenderOutput is the outcome of rendering a Composition.
+type RenderOutput struct {
+	// CompositeResource is the desired composite resource.
+	CompositeResource *unstructured.Unstructured
+
+	// ComposedResources are the desired composed resources, sorted by their
+	// composition resource name.
+	ComposedResources []*unstructured.Unstructured
+
+	// Results are the results returned by all pipeline steps.
+	Results []*fnapi.Result
+
+	// SkippedSteps are the names of pipeline steps whose input is not a
+	// ServerInput.
+	SkippedSteps []string
+}
+
+// Render runs all pipeline steps of a Composition whose input is a
+// ServerInput against srv, like Crossplane would for the given composite
+// resource and observed composed resources.
+//
+// Observed composed resources must be annotated with
+// AnnotationKeyCompositionResourceName.
+// Rendering stops at the first step that returns a fatal result.
+func Render(ctx context.Context, srv *Server, xr, composition *unstructured.Unstructured, observed ...*unstructured.Unstructured) (*RenderOutput, error) {
+	xrStruct, err := structpb.NewStruct(xr.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot convert composite resource")
+	}
+	req := &fnapi.RunFunctionRequest{
+		Observed: &fnapi.State{
+			Composite: &fnapi.Resource{Resource: xrStruct},
+			Resources: map[string]*fnapi.Resource{},
+		},
+		Desired: &fnapi.State{},
+	}
+	for _, o := range observed {
+		name := o.GetAnnotations()[AnnotationKeyCompositionResourceName]
+		if name == "" {
+			return nil, errors.Errorf("observed resource %q is missing annotation %q", o.GetName(), AnnotationKeyCompositionResourceName)
+		}
+		s, err := structpb.NewStruct(o.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert observed resource %q", name)
+		}
+		req.Observed.Resources[name] = &fnapi.Resource{Resource: s}
+	}
+
+	steps, err := pipelineSteps(composition)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &RenderOutput{}
+	for _, step := range steps {
+		gv, _ := schema.ParseGroupVersion(step.input.GetFields()["apiVersion"].GetStringValue())
+		if gv.Group != v1beta1.CRDGroup {
+			out.SkippedSteps = append(out.SkippedSteps, step.name)
+			continue
+		}
+		req.Input = step.input
+		res, err := srv.RunFunction(ctx, req)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot run pipeline step %q", step.name)
+		}
+		out.Results = append(out.Results, res.GetResults()...)
+		for _, r := range res.GetResults() {
+			if r.GetSeverity() == fnapi.Severity_SEVERITY_FATAL {
+				return nil, errors.Errorf("pipeline step %q returned a fatal result: %s", step.name, r.GetMessage())
+			}
+		}
+		req.Desired = res.GetDesired()
+		req.Context = res.GetContext()
+	}
+
+	out.CompositeResource = desiredComposite(xr, req.GetDesired().GetComposite())
+	names := make([]string, 0, len(req.GetDesired().GetResources()))
+	for name := range req.GetDesired().GetResources() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cd := &unstructured.Unstructured{Object: req.GetDesired().GetResources()[name].GetResource().AsMap()}
+		annotations := cd.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AnnotationKeyCompositionResourceName] = name
+		cd.SetAnnotations(annotations)
+		labels := cd.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[LabelKeyComposite] = xr.GetName()
+		cd.SetLabels(labels)
+		out.ComposedResources = append(out.ComposedResources, cd)
+	}
+	return out, nil
+}
+
+// WriteYAML writes the desired composite resource followed by all desired
+// composed resources to w as a YAML stream.
+func (o *RenderOutput) WriteYAML(w io.Writer) error {
+	for _, u := range append([]*unstructured.Unstructured{o.CompositeResource}, o.ComposedResources...) {
+		raw, err := sigsyaml.Marshal(u.Object)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal %s %q", u.GetKind(), u.GetName())
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", raw); err != nil {
+			return errors.Wrap(err, "cannot write output")
+		}
+	}
+	return nil
+}
+
+type pipelineStep struct {
+	name  string
+	input *structpb.Struct
+}
+
+// pipelineSteps returns the pipeline steps of a Composition.
+func pipelineSteps(composition *unstructured.Unstructured) ([]pipelineStep, error) {
+	if mode, _, _ := unstructured.NestedString(composition.Object, "spec", "mode"); mode != "Pipeline" {
+		return nil, errors.Errorf("composition %q must use mode Pipeline", composition.GetName())
+	}
+	pipeline, _, err := unstructured.NestedSlice(composition.Object, "spec", "pipeline")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read composition pipeline")
+	}
+	steps := make([]pipelineStep, len(pipeline))
+	for i, p := range pipeline {
+		m, ok := p.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("pipeline step %d is not an object", i)
+		}
+		steps[i].name, _ = m["step"].(string)
+		input, _ := m["input"].(map[string]any)
+		if steps[i].input, err = structpb.NewStruct(input); err != nil {
+			return nil, errors.Wrapf(err, "cannot convert input of pipeline step %q", steps[i].name)
+		}
+	}
+	return steps, nil
+}
+
+// desiredComposite returns the observed composite resource xr with the
+// desired fields of the composite set by the pipeline.
+func desiredComposite(xr *unstructured.Unstructured, desired *fnapi.Resource) *unstructured.Unstructured {
+	out := &unstructured.Unstructured{Object: map[string]any{}}
+	if desired != nil {
+		out.Object = desired.GetResource().AsMap()
+	}
+	out.SetAPIVersion(xr.GetAPIVersion())
+	out.SetKind(xr.GetKind())
+	out.SetName(xr.GetName())
+	return out
+}
+
+// readObjects reads all objects of a YAML stream from a file.
+func readObjects(path string) ([]*unstructured.Unstructured, error) {
+	raw, err := os.ReadFile(path) //nolint:gosec // Reading user supplied files is intended.
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read %q", path)
+	}
+	dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(raw), 4096)
+	var objs []*unstructured.Unstructured
+	for {
+		u := &unstructured.Unstructured{}
+		err := dec.Decode(&u.Object)
+		if errors.Is(err, io.EOF) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot decode %q", path)
+		}
+		if len(u.Object) > 0 {
+			objs = append(objs, u)
+		}
+	}
+}
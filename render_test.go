@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+func mustUnstructured(t *testing.T, rawYAML string) *unstructured.Unstructured {
+	t.Helper()
+	u := &unstructured.Unstructured{}
+	if err := sigsyaml.Unmarshal([]byte(rawYAML), &u.Object); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRender(t *testing.T) {
+	// bucketFunction composes a bucket whose name is read from its input
+	// and copies the region of the observed bucket into the XR status.
+	bucketFunction := testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+		input := map[string]any{}
+		if err := req.GetInput(&input); err != nil {
+			return err
+		}
+		observed := &unstructured.Unstructured{}
+		_ = req.GetComposed("bucket", observed)
+		region, _, _ := unstructured.NestedString(observed.Object, "status", "region")
+		res.SetCompositeRaw(&fnapi.Resource{Resource: mustStruct(t, map[string]any{
+			"status": map[string]any{"region": region},
+		})})
+		res.SetComposedRaw("bucket", &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+			"apiVersion": "s3.example.com/v1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"name": input["name"]},
+		})})
+		return nil
+	})
+	fatalFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		res.SetNativeResults([]*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_FATAL, Message: "boom"}})
+		return nil
+	})
+	srv := NewServer(
+		WithFunction("bucket", bucketFunction),
+		WithFunction("fatal", fatalFunction),
+	)
+
+	xr := mustUnstructured(t, `
+apiVersion: example.com/v1alpha1
+kind: Example
+metadata:
+  name: example
+spec: {}
+`)
+	observedBucket := mustUnstructured(t, `
+apiVersion: s3.example.com/v1
+kind: Bucket
+metadata:
+  name: my-bucket
+  annotations:
+    crossplane.io/composition-resource-name: bucket
+status:
+  region: eu-central-1
+`)
+
+	type want struct {
+		yaml    string
+		skipped []string
+		err     bool
+	}
+	cases := map[string]struct {
+		composition string
+		want        want
+	}{
+		"RenderServerSteps": {
+			composition: `
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: example
+spec:
+  mode: Pipeline
+  pipeline:
+  - step: other
+    functionRef:
+      name: function-patch-and-transform
+    input:
+      apiVersion: pt.fn.crossplane.io/v1beta1
+      kind: Resources
+  - step: bucket
+    functionRef:
+      name: server
+    input:
+      apiVersion: server.fn.crossplane.io/v1beta1
+      kind: ServerInput
+      spec:
+        functionName: bucket
+        input:
+          name: my-bucket
+`,
+			want: want{
+				yaml: `---
+apiVersion: example.com/v1alpha1
+kind: Example
+metadata:
+  name: example
+status:
+  region: eu-central-1
+---
+apiVersion: s3.example.com/v1
+kind: Bucket
+metadata:
+  annotations:
+    crossplane.io/composition-resource-name: bucket
+  labels:
+    crossplane.io/composite: example
+  name: my-bucket
+`,
+				skipped: []string{"other"},
+			},
+		},
+		"FatalResult": {
+			composition: `
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: example
+spec:
+  mode: Pipeline
+  pipeline:
+  - step: fatal
+    functionRef:
+      name: server
+    input:
+      apiVersion: server.fn.crossplane.io/v1beta1
+      kind: ServerInput
+      spec:
+        functionName: fatal
+`,
+			want: want{err: true},
+		},
+		"NotPipelineMode": {
+			composition: `
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: example
+spec:
+  resources: []
+`,
+			want: want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := Render(context.Background(), srv, xr, mustUnstructured(t, tc.composition), observedBucket)
+			if tc.want.err {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			if err := out.WriteYAML(buf); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.yaml, buf.String()); diff != "" {
+				t.Errorf("YAML: -want +got\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.skipped, out.SkippedSteps); diff != "" {
+				t.Errorf("SkippedSteps: -want +got\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+
+	evtv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+// An InputSchemaProvider is a ServerFunction that provides the OpenAPI v3
+// schema of its input.
+//
+// If a ServerFunction does not implement InputSchemaProvider, its schema is
+// derived from the type returned by InputFactory.
+type InputSchemaProvider interface {
+	InputSchema() *evtv1.JSONSchemaProps
+}
+
+// InputSchema returns the OpenAPI v3 schema of the input of a ServerFunction.
+//
+// ServerFunctions that neither implement InputSchemaProvider nor
+// InputFactory accept any object.
+func (s *Server) InputSchema(name string) (*evtv1.JSONSchemaProps, error) {
+	reg, _, ok := s.lookup(name)
+	if !ok {
+		return nil, NewErrorNotFound(name)
+	}
+	if p, ok := reg.fn.(InputSchemaProvider); ok {
+		return p.InputSchema(), nil
+	}
+	if f, ok := reg.fn.(InputFactory); ok {
+		return schemaOf(reflect.TypeOf(f.NewInput()), map[reflect.Type]bool{}), nil
+	}
+	return anySchema(), nil
+}
+
+var (
+	typeTime         = reflect.TypeOf(metav1.Time{})
+	typeDuration     = reflect.TypeOf(metav1.Duration{})
+	typeQuantity     = reflect.TypeOf(resource.Quantity{})
+	typeJSON         = reflect.TypeOf(evtv1.JSON{})
+	typeRawExtension = reflect.TypeOf(runtime.RawExtension{})
+)
+
+func anySchema() *evtv1.JSONSchemaProps {
+	return &evtv1.JSONSchemaProps{
+		Type:                   "object",
+		XPreserveUnknownFields: ptr.To(true),
+	}
+}
+
+// schemaOf derives the schema of a Go type from its JSON encoding.
+// Types that are currently visited are not expanded again to support
+// recursive types.
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) *evtv1.JSONSchemaProps {
+	if t == nil {
+		return anySchema()
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case typeTime:
+		return &evtv1.JSONSchemaProps{Type: "string", Format: "date-time"}
+	case typeDuration:
+		return &evtv1.JSONSchemaProps{Type: "string"}
+	case typeQuantity:
+		return &evtv1.JSONSchemaProps{XIntOrString: true}
+	case typeJSON, typeRawExtension:
+		return &evtv1.JSONSchemaProps{XPreserveUnknownFields: ptr.To(true)}
+	}
+
+	switch t.Kind() { //nolint:exhaustive // Remaining kinds cannot be encoded as JSON.
+	case reflect.Bool:
+		return &evtv1.JSONSchemaProps{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &evtv1.JSONSchemaProps{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &evtv1.JSONSchemaProps{Type: "number"}
+	case reflect.String:
+		return &evtv1.JSONSchemaProps{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &evtv1.JSONSchemaProps{Type: "string", Format: "byte"}
+		}
+		return &evtv1.JSONSchemaProps{
+			Type:  "array",
+			Items: &evtv1.JSONSchemaPropsOrArray{Schema: schemaOf(t.Elem(), visiting)},
+		}
+	case reflect.Map:
+		return &evtv1.JSONSchemaProps{
+			Type:                 "object",
+			AdditionalProperties: &evtv1.JSONSchemaPropsOrBool{Allows: true, Schema: schemaOf(t.Elem(), visiting)},
+		}
+	case reflect.Struct:
+		if visiting[t] {
+			return anySchema()
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		s := &evtv1.JSONSchemaProps{Type: "object", Properties: map[string]evtv1.JSONSchemaProps{}}
+		addStructFields(s, t, visiting)
+		return s
+	default:
+		return anySchema()
+	}
+}
+
+// addStructFields adds the JSON encoded fields of struct type t to s.
+func addStructFields(s *evtv1.JSONSchemaProps, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if (f.Anonymous && name == "" || strings.Contains(opts, "inline")) && ft.Kind() == reflect.Struct {
+			addStructFields(s, ft, visiting)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = *schemaOf(f.Type, visiting)
+		switch f.Type.Kind() { //nolint:exhaustive // Only nil-able kinds are optional.
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		default:
+			if !strings.Contains(opts, "omitempty") {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	evtv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+type schemaBase struct {
+	ID string `json:"id"`
+}
+
+type schemaNode struct {
+	schemaBase `json:",inline"`
+
+	Timeout  metav1.Duration `json:"timeout"`
+	Data     []byte          `json:"data,omitempty"`
+	Raw      evtv1.JSON      `json:"raw,omitempty"`
+	Children []schemaNode    `json:"children,omitempty"`
+	Ignored  string          `json:"-"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	cases := map[string]struct {
+		typ  reflect.Type
+		want *evtv1.JSONSchemaProps
+	}{
+		"Nil": {
+			want: &evtv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: ptr.To(true)},
+		},
+		"Struct": {
+			typ: reflect.TypeOf(&schemaNode{}),
+			want: &evtv1.JSONSchemaProps{
+				Type: "object",
+				Properties: map[string]evtv1.JSONSchemaProps{
+					"id":      {Type: "string"},
+					"timeout": {Type: "string"},
+					"data":    {Type: "string", Format: "byte"},
+					"raw":     {XPreserveUnknownFields: ptr.To(true)},
+					"children": {
+						Type: "array",
+						Items: &evtv1.JSONSchemaPropsOrArray{Schema: &evtv1.JSONSchemaProps{
+							Type:                   "object",
+							XPreserveUnknownFields: ptr.To(true),
+						}},
+					},
+				},
+				Required: []string{"id", "timeout"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := schemaOf(tc.typ, map[reflect.Type]bool{})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,8 +1,41 @@
 package server
 
+import (
+	"sort"
+	"time"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	"github.com/pkg/errors"
+)
+
 // ServerOption that configures a function Server.
 type ServerOption func(server *Server)
 
+// FunctionOption configures how a ServerFunction is registered at a Server.
+type FunctionOption func(fn *registeredFunction)
+
+// registeredFunction is a ServerFunction together with the options it was
+// registered with.
+type registeredFunction struct {
+	fn      ServerFunction
+	version string
+	timeout time.Duration
+	limiter *limiter
+	cache   *responseCache
+
+	// aliases maps additional names to their deprecation message, which is
+	// empty if the alias is not deprecated.
+	aliases     map[string]string
+	deprecation string
+
+	access   []AccessRule
+	rawInput bool
+
+	// Set by the server configuration.
+	disabled     bool
+	defaultInput []byte
+}
+
 // NewServer create a new Server instance that implements the Crossplane
 // Function interface and is able to serve multiple subfunctions
 // (aka server functions) at the same time.
@@ -14,19 +47,136 @@ type ServerOption func(server *Server)
 //		server.WithFunction(&MyOtherFunction{})
 //		// ...
 //	)
+//
+// NewServer panics if the ServerFunctions cannot be registered, see New.
 func NewServer(opts ...ServerOption) *Server {
+	server, err := New(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return server
+}
+
+// New is like NewServer but returns an error if the ServerFunctions cannot
+// be registered. This is the case if a name is registered twice, or if an
+// alias is registered by more than one ServerFunction or is the name of a
+// ServerFunction, since it could not be routed reliably.
+func New(opts ...ServerOption) (*Server, error) {
 	server := &Server{
-		functions: map[string]ServerFunction{},
+		functions: map[string]*registeredFunction{},
+		tracer:    defaultTracer(),
+		log:       logging.NewNopLogger(),
 	}
 	for _, o := range opts {
 		o(server)
 	}
-	return server
+	if server.err != nil {
+		return nil, server.err
+	}
+	if err := validateAliases(server.functions); err != nil {
+		return nil, err
+	}
+	server.registered = server.functions
+	server.aliases = registeredAliases(server.registered)
+	return server, nil
 }
 
 // WithFunction registers a ServerFunction at a Server with a given name.
-func WithFunction(name string, fn ServerFunction) ServerOption {
+// Each name can only be registered once.
+func WithFunction(name string, fn ServerFunction, opts ...FunctionOption) ServerOption {
 	return func(server *Server) {
-		server.functions[name] = fn
+		if _, ok := server.functions[name]; ok {
+			if server.err == nil {
+				server.err = errors.Errorf("server function %q is registered twice", name)
+			}
+			return
+		}
+		reg := &registeredFunction{fn: fn}
+		for _, o := range opts {
+			o(reg)
+		}
+		server.functions[name] = reg
+	}
+}
+
+// WithVersion sets the version of a ServerFunction.
+//
+// Compositions can request a specific version by setting functionVersion in
+// their ServerInput.
+func WithVersion(version string) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.version = version
+	}
+}
+
+// FunctionInfo describes a registered ServerFunction.
+type FunctionInfo struct {
+	// Name of the ServerFunction.
+	Name string
+
+	// Version of the ServerFunction, if any.
+	Version string
+
+	// Timeout of the ServerFunction, if any.
+	Timeout time.Duration
+
+	// Aliases of the ServerFunction sorted by name, if any.
+	Aliases []string
+
+	// Deprecation message of the ServerFunction, if it is deprecated.
+	Deprecation string
+
+	// Disabled is true if the ServerFunction is disabled by the server
+	// configuration.
+	Disabled bool
+}
+
+// Functions returns all registered ServerFunctions sorted by name.
+func (s *Server) Functions() []FunctionInfo {
+	s.mu.RLock()
+	functions, aliases := s.functions, s.aliases
+	s.mu.RUnlock()
+
+	byName := map[string][]string{}
+	for a, name := range aliases {
+		byName[name] = append(byName[name], a)
+	}
+	infos := make([]FunctionInfo, 0, len(functions))
+	for name, reg := range functions {
+		sort.Strings(byName[name])
+		infos = append(infos, FunctionInfo{
+			Name:        name,
+			Version:     reg.version,
+			Timeout:     reg.timeout,
+			Aliases:     byName[name],
+			Deprecation: reg.deprecation,
+			Disabled:    reg.disabled,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// snapshot returns the currently configured ServerFunctions by name. The
+// returned map must not be modified.
+func (s *Server) snapshot() map[string]*registeredFunction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.functions
+}
+
+// lookup returns the currently configured ServerFunction with the given
+// name or alias together with its name.
+func (s *Server) lookup(name string) (*registeredFunction, string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if reg, ok := s.functions[name]; ok {
+		return reg, name, true
+	}
+	target, ok := s.aliases[name]
+	if !ok {
+		return nil, name, false
 	}
+	reg, ok := s.functions[target]
+	return reg, target, ok
 }
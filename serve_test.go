@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	cases := map[string]struct {
+		opts []ServerOption
+		want string
+	}{
+		"Functions": {
+			opts: []ServerOption{
+				WithFunction("a", noop, WithAliases("old-a")),
+				WithFunction("b", noop),
+			},
+		},
+		"DuplicateName": {
+			opts: []ServerOption{
+				WithFunction("a", noop),
+				WithFunction("b", noop),
+				WithFunction("a", noop),
+			},
+			want: `server function "a" is registered twice`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tc.opts...)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("Expected error %q but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewServerPanics(t *testing.T) {
+	noop := testFunction(func(_ context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		return nil
+	})
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected NewServer to panic")
+		}
+	}()
+	NewServer(WithFunction("a", noop), WithFunction("a", noop))
+}
@@ -3,14 +3,22 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"sync"
+	"time"
 
+	"github.com/crossplane/function-sdk-go/logging"
 	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
 	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/response"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
+	evtv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/mistermx/crossplane-function-server/apis/v1alpha1"
+	"github.com/mistermx/crossplane-function-server/apis/v1beta1"
 )
 
 // Server is a special Crossplane function which acts as a router that
@@ -21,33 +29,99 @@ import (
 type Server struct {
 	fnapi.UnimplementedFunctionRunnerServiceServer
 
-	functions map[string]ServerFunction
+	// registered are the ServerFunctions registered via WithFunction.
+	// functions and aliases are derived from them by ApplyConfig.
+	registered map[string]*registeredFunction
+
+	mu        sync.RWMutex
+	functions map[string]*registeredFunction
+	aliases   map[string]string
+
+	templater InputTemplater
+	metrics   *Metrics
+	tracer    trace.Tracer
+	log       logging.Logger
+	recordDir string
+
+	// err is the first error of a ServerOption. It is returned by New.
+	err error
 }
 
-func (s *Server) RunFunction(ctx context.Context, req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
-	serverInput := &v1alpha1.ServerInput{}
-	if err := resource.AsObject(req.Input, serverInput); err != nil {
-		return nil, errors.Wrap(err, "cannot parse input")
-	}
+func (s *Server) RunFunction(ctx context.Context, req *fnapi.RunFunctionRequest) (res *fnapi.RunFunctionResponse, err error) {
+	ctx, span := s.tracer.Start(ctx, "RunFunction", trace.WithAttributes(compositeAttributes(req)...))
+	defer func() { endSpan(span, res.GetResults(), err) }()
 
-	fn, exists := s.functions[serverInput.Spec.FunctionName]
-	if !exists {
-		return nil, errors.Errorf("no function with name %q", serverInput.Spec.FunctionName)
+	log := requestLogger(s.log, req)
+	ctx = ContextWithLogger(ctx, log)
+
+	if s.recordDir != "" {
+		// Copy the request before it is modified by the server functions.
+		recReq := redactRequest(req)
+		defer func() {
+			if rerr := s.record(recReq, res, err); rerr != nil {
+				log.Info("Cannot record request", "error", rerr)
+			}
+		}()
 	}
 
-	fnReq := RunServerFunctionRequest{
-		Req:         req,
-		ServerInput: serverInput,
+	serverInput, err := parseServerInput(req.GetInput())
+	if IsErrorInvalidInput(err) {
+		res := response.To(req, response.DefaultTTL)
+		response.Fatal(res, errors.Wrap(err, "cannot parse input"))
+		return res, nil
 	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse input")
+	}
+
 	fnRes := RunServerFunctionResponse{}
-	if err := fn.Run(ctx, &fnReq, &fnRes); err != nil {
-		return nil, errors.Wrapf(err, "error while running subroutine function %q", serverInput.Spec.FunctionName)
+	for i, step := range serverInput.Spec.GetSteps() {
+		stepReq := req
+		if i > 0 {
+			stepReq = fnRes.nextStepRequest(req)
+		}
+		// Label with the name the alias resolves to. Unknown names share a
+		// metrics label, so callers cannot create arbitrary series.
+		_, name, known := s.lookup(step.FunctionName)
+		metricsName := name
+		if !known {
+			metricsName = MetricsLabelUnknownFunction
+		}
+		stepCtx, stepSpan := s.tracer.Start(ctx, "ServerFunction "+name, trace.WithAttributes(
+			AttributeFunctionName.String(name),
+			AttributeFunctionVersion.String(step.FunctionVersion),
+		))
+		stepLog := log.WithValues("function", name)
+		if name != step.FunctionName {
+			stepLog = stepLog.WithValues("alias", step.FunctionName)
+		}
+		stepCtx = ContextWithLogger(stepCtx, stepLog)
+		stepLog.Debug("Running server function")
+
+		start := time.Now()
+		err := s.runStep(stepCtx, stepReq, serverInput, step, &fnRes)
+		s.metrics.observe(metricsName, start, &fnRes, err)
+		endSpan(stepSpan, fnRes.Results, err)
+		if err != nil {
+			stepLog.Info("Server function failed", "error", err)
+		}
+		if IsErrorInvalidInput(err) || IsErrorTimeout(err) || IsErrorDisabled(err) || IsErrorDenied(err) {
+			res := response.To(req, response.DefaultTTL)
+			response.Fatal(res, err)
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	res := &fnapi.RunFunctionResponse{
+	res = &fnapi.RunFunctionResponse{
 		Desired: req.GetDesired(),
 		Context: req.GetContext(),
 	}
+	if res.Desired == nil {
+		res.Desired = &fnapi.State{}
+	}
 	if fnRes.DesiredComposite != nil {
 		res.Desired.Composite = fnRes.DesiredComposite
 	}
@@ -63,9 +137,120 @@ func (s *Server) RunFunction(ctx context.Context, req *fnapi.RunFunctionRequest)
 	return res, nil
 }
 
+// runStep runs the ServerFunction of a single step and writes its results
+// into fnRes.
+func (s *Server) runStep(ctx context.Context, req *fnapi.RunFunctionRequest, serverInput *v1beta1.ServerInput, step v1beta1.ServerInputStep, fnRes *RunServerFunctionResponse) (err error) {
+	reg, name, exists := s.lookup(step.FunctionName)
+	if !exists {
+		return errors.Wrap(NewErrorNotFound(step.FunctionName), "unknown server function")
+	}
+	if msg := reg.deprecationOf(step.FunctionName); msg != "" {
+		requested := step.FunctionName
+		LoggerFrom(ctx).Info("Deprecated server function called", "deprecation", msg)
+		s.metrics.observeDeprecated(name, requested)
+		defer func() {
+			if err == nil {
+				fnRes.Results = append(fnRes.Results, deprecationWarning(requested, msg))
+			}
+		}()
+	}
+	step.FunctionName = name
+	if reg.disabled {
+		return errDisabled{name: name}
+	}
+	if err := checkAccess(name, reg.access, req); err != nil {
+		return err
+	}
+	if step.FunctionVersion != "" && step.FunctionVersion != reg.version {
+		return errors.Errorf("function %q has version %q but version %q was requested", step.FunctionName, reg.version, step.FunctionVersion)
+	}
+
+	input, err := mergeDefaultInput(reg.defaultInput, step.Input.Raw)
+	if err != nil {
+		return errors.Wrapf(err, "cannot apply default input of subroutine function %q", step.FunctionName)
+	}
+	if s.templater != nil && reg.rendersInput() {
+		rendered, err := renderInput(input, s.templater, templateData(req))
+		if err != nil {
+			return errors.Wrap(err, "cannot render input")
+		}
+		input = rendered
+	}
+
+	input, err = prepareInput(reg.fn, input)
+	if err != nil {
+		return errors.Wrapf(err, "cannot prepare input of subroutine function %q", step.FunctionName)
+	}
+
+	var cacheKey string
+	if reg.cache != nil {
+		cacheKey = reg.cache.key(step.FunctionName, input, req, fnRes)
+		cached, hit := reg.cache.get(cacheKey)
+		s.metrics.observeCache(step.FunctionName, hit)
+		if hit {
+			*fnRes = *cached
+			return nil
+		}
+	}
+
+	timeout := reg.timeout
+	if step.Timeout != nil && step.Timeout.Duration > 0 {
+		timeout = step.Timeout.Duration
+	}
+
+	stepInput := serverInput.DeepCopy()
+	stepInput.Spec = v1beta1.ServerInputSpec{
+		FunctionName:    step.FunctionName,
+		FunctionVersion: step.FunctionVersion,
+		Timeout:         step.Timeout,
+		Input:           evtv1.JSON{Raw: input},
+	}
+	fnReq := RunServerFunctionRequest{
+		Req:         req,
+		ServerInput: stepInput,
+	}
+	// The slot is released when the function returns, so functions that
+	// keep running after their timeout still count against the limit.
+	err = runWithTimeout(ctx, step.FunctionName, timeout, reg.fn, &fnReq, fnRes, reg.limiter)
+	if IsErrorTimeout(err) {
+		return err
+	}
+	if IsErrorSaturated(err) {
+		return errors.Wrapf(err, "cannot run subroutine function %q", step.FunctionName)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error while running subroutine function %q", step.FunctionName)
+	}
+	if reg.cache != nil {
+		reg.cache.set(cacheKey, fnRes)
+	}
+	return nil
+}
+
 type RunServerFunctionRequest struct {
-	Req         *fnapi.RunFunctionRequest
-	ServerInput *v1alpha1.ServerInput
+	Req *fnapi.RunFunctionRequest
+
+	// ServerInput of the step that is run. Its spec contains only the call
+	// of the step.
+	//
+	// Before v1beta1 this was a *v1alpha1.ServerInput. Use
+	// V1Alpha1ServerInput to get the input in that form.
+	ServerInput *v1beta1.ServerInput
+}
+
+// V1Alpha1ServerInput returns the ServerInput of the step as v1alpha1, for
+// ServerFunctions written against v1alpha1. The functionVersion and timeout
+// of the step are not part of v1alpha1 and are omitted.
+func (r *RunServerFunctionRequest) V1Alpha1ServerInput() *v1alpha1.ServerInput {
+	in := &v1alpha1.ServerInput{
+		ObjectMeta: *r.ServerInput.ObjectMeta.DeepCopy(),
+		Spec: v1alpha1.ServerInputSpec{
+			FunctionName: r.ServerInput.Spec.FunctionName,
+			Input:        *r.ServerInput.Spec.Input.DeepCopy(),
+		},
+	}
+	in.SetGroupVersionKind(v1alpha1.ServerInputGroupVersionKind)
+	return in
 }
 
 func (r *RunServerFunctionRequest) GetNativeRequest() *fnapi.RunFunctionRequest {
@@ -96,6 +281,38 @@ type RunServerFunctionResponse struct {
 	Results          []*fnapi.Result
 }
 
+// nextStepRequest returns a copy of req whose desired state and context
+// include the changes of this response, so that the next step sees them.
+func (r *RunServerFunctionResponse) nextStepRequest(req *fnapi.RunFunctionRequest) *fnapi.RunFunctionRequest {
+	next := proto.Clone(req).(*fnapi.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+	next.Desired, next.Context = r.desiredState(req)
+	return next
+}
+
+// DeepCopy returns a deep copy of this response.
+func (r *RunServerFunctionResponse) DeepCopy() *RunServerFunctionResponse {
+	out := &RunServerFunctionResponse{}
+	if r.DesiredComposite != nil {
+		out.DesiredComposite = proto.Clone(r.DesiredComposite).(*fnapi.Resource)
+	}
+	if r.DesiredComposed != nil {
+		out.DesiredComposed = make(map[string]*fnapi.Resource, len(r.DesiredComposed))
+		for name, res := range r.DesiredComposed {
+			out.DesiredComposed[name] = proto.Clone(res).(*fnapi.Resource)
+		}
+	}
+	if r.DesiredContext != nil {
+		out.DesiredContext = proto.Clone(r.DesiredContext).(*structpb.Struct)
+	}
+	if r.Results != nil {
+		out.Results = make([]*fnapi.Result, len(r.Results))
+		for i, res := range r.Results {
+			out.Results[i] = proto.Clone(res).(*fnapi.Result)
+		}
+	}
+	return out
+}
+
 func (r *RunServerFunctionResponse) SetCompositeRaw(res *fnapi.Resource) {
 	r.DesiredComposite = res
 }
@@ -5,6 +5,7 @@ import (
 
 	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // A ServerFunction is a high-level subroutine of native Crossplane Go function.
@@ -13,6 +14,36 @@ type ServerFunction interface {
 	Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error
 }
 
+// An InputFactory is a ServerFunction that declares the type of its input.
+//
+// The object returned by NewInput is what gets passed to InputDefaulter and
+// InputValidator. If a ServerFunction does not implement InputFactory, its
+// input is passed as map[string]any instead.
+type InputFactory interface {
+	// NewInput returns a pointer to a new, empty input object.
+	NewInput() any
+}
+
+// An InputDefaulter is a ServerFunction that applies default values to its
+// input before it is run.
+//
+// Defaults are visible to the ServerFunction through
+// ServerFunctionRequest.GetInput.
+type InputDefaulter interface {
+	// Default sets default values on the given input.
+	Default(input any)
+}
+
+// An InputValidator is a ServerFunction that validates its input before it
+// is run.
+//
+// Validation happens after defaulting. If any errors are returned, the
+// ServerFunction is not run and the errors are reported as a fatal result.
+type InputValidator interface {
+	// ValidateInput validates the given input.
+	ValidateInput(input any) field.ErrorList
+}
+
 // ServerFunctionRequest provides ways to easily the request payload of a
 // ServerFunction call.
 type ServerFunctionRequest interface {
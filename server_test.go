@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	evtv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/mistermx/crossplane-function-server/apis/v1alpha1"
+)
+
+// testFunction is a ServerFunction that delegates to a plain function.
+type testFunction func(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error
+
+func (f testFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	return f(ctx, req, res)
+}
+
+// newTestRequest returns a RunFunctionRequest that calls the server function
+// with the given name and input.
+func newTestRequest(t *testing.T, functionName string, input map[string]any) *fnapi.RunFunctionRequest {
+	t.Helper()
+	return &fnapi.RunFunctionRequest{
+		Input: mustStruct(t, map[string]any{
+			"apiVersion": "server.fn.crossplane.io/v1alpha1",
+			"kind":       "ServerInput",
+			"spec": map[string]any{
+				"functionName": functionName,
+				"input":        input,
+			},
+		}),
+		Observed: &fnapi.State{},
+		Desired:  &fnapi.State{},
+	}
+}
+
+func TestRunFunctionSteps(t *testing.T) {
+	// appendFunction appends its input to the context field "calls".
+	appendFunction := testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+		input := map[string]any{}
+		if err := req.GetInput(&input); err != nil {
+			return err
+		}
+		r := res.(*RunServerFunctionResponse)
+		calls := []any{}
+		if r.DesiredContext != nil {
+			calls = r.DesiredContext.Fields["calls"].GetListValue().AsSlice()
+		}
+		return res.SetContextField("calls", append(calls, input["name"]))
+	})
+
+	type want struct {
+		calls []any
+		fatal string
+		err   bool
+	}
+	cases := map[string]struct {
+		input *structpb.Struct
+		want
+	}{
+		"V1Alpha1": {
+			input: mustStruct(t, map[string]any{
+				"apiVersion": "server.fn.crossplane.io/v1alpha1",
+				"kind":       "ServerInput",
+				"spec": map[string]any{
+					"functionName": "append",
+					"input":        map[string]any{"name": "a"},
+				},
+			}),
+			want: want{
+				calls: []any{"a"},
+			},
+		},
+		"V1Beta1Steps": {
+			input: mustStruct(t, map[string]any{
+				"apiVersion": "server.fn.crossplane.io/v1beta1",
+				"kind":       "ServerInput",
+				"spec": map[string]any{
+					"steps": []any{
+						map[string]any{"functionName": "append", "input": map[string]any{"name": "a"}},
+						map[string]any{"functionName": "append", "functionVersion": "v2", "input": map[string]any{"name": "b"}},
+					},
+				},
+			}),
+			want: want{
+				calls: []any{"a", "b"},
+			},
+		},
+		"VersionMismatch": {
+			input: mustStruct(t, map[string]any{
+				"apiVersion": "server.fn.crossplane.io/v1beta1",
+				"kind":       "ServerInput",
+				"spec": map[string]any{
+					"functionName":    "append",
+					"functionVersion": "v1",
+				},
+			}),
+			want: want{
+				err: true,
+			},
+		},
+		"OtherAPIVersion": {
+			// Inputs of unknown API versions are parsed as v1alpha1.
+			input: mustStruct(t, map[string]any{
+				"apiVersion": "server.fn.crossplane.io/v1",
+				"kind":       "ServerInput",
+				"spec": map[string]any{
+					"functionName": "append",
+					"input":        map[string]any{"name": "a"},
+				},
+			}),
+			want: want{
+				calls: []any{"a"},
+			},
+		},
+		"EmptyAPIVersion": {
+			input: mustStruct(t, map[string]any{
+				"spec": map[string]any{
+					"functionName": "append",
+					"input":        map[string]any{"name": "a"},
+				},
+			}),
+			want: want{
+				calls: []any{"a"},
+			},
+		},
+		"StepsAndFunctionName": {
+			input: mustStruct(t, map[string]any{
+				"apiVersion": "server.fn.crossplane.io/v1beta1",
+				"kind":       "ServerInput",
+				"spec": map[string]any{
+					"functionName": "append",
+					"steps": []any{
+						map[string]any{"functionName": "append", "input": map[string]any{"name": "a"}},
+					},
+				},
+			}),
+			want: want{
+				calls: []any{},
+				fatal: "cannot parse input: invalid input: spec.functionName: Forbidden: must not be set together with steps",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := NewServer(WithFunction("append", appendFunction, WithVersion("v2")))
+			res, err := srv.RunFunction(context.Background(), &fnapi.RunFunctionRequest{Input: tc.input})
+			if (err != nil) != tc.want.err {
+				t.Fatalf("Expected error %v but got %v", tc.want.err, err)
+			}
+			if err != nil {
+				return
+			}
+			var fatal string
+			for _, r := range res.GetResults() {
+				if r.GetSeverity() == fnapi.Severity_SEVERITY_FATAL {
+					fatal = r.GetMessage()
+				}
+			}
+			if fatal != tc.want.fatal {
+				t.Errorf("Expected fatal result %q but got %q", tc.want.fatal, fatal)
+			}
+			if diff := cmp.Diff(tc.want.calls, res.GetContext().GetFields()["calls"].GetListValue().AsSlice()); diff != "" {
+				t.Errorf("Calls: -want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionStepsDesiredState(t *testing.T) {
+	// step sets a composed resource and context field named by its input and
+	// records the desired resources and context of its native request.
+	var seen [][]string
+	step := testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+		input := map[string]string{}
+		if err := req.GetInput(&input); err != nil {
+			return err
+		}
+		native := req.GetNativeRequest()
+		var got []string
+		for _, name := range sortedKeys(native.GetDesired().GetResources()) {
+			got = append(got, "resource:"+name)
+		}
+		for _, key := range sortedKeys(native.GetContext().GetFields()) {
+			got = append(got, "context:"+key)
+		}
+		seen = append(seen, got)
+		res.SetComposedRaw(input["name"], &fnapi.Resource{Resource: &structpb.Struct{}})
+		return res.SetContextField(input["name"], true)
+	})
+	srv := NewServer(WithFunction("step", step))
+
+	req := &fnapi.RunFunctionRequest{
+		Input: mustStruct(t, map[string]any{
+			"apiVersion": "server.fn.crossplane.io/v1beta1",
+			"kind":       "ServerInput",
+			"spec": map[string]any{
+				"steps": []any{
+					map[string]any{"functionName": "step", "input": map[string]any{"name": "a"}},
+					map[string]any{"functionName": "step", "input": map[string]any{"name": "b"}},
+					map[string]any{"functionName": "step", "input": map[string]any{"name": "c"}},
+				},
+			},
+		}),
+		Desired: &fnapi.State{},
+	}
+	if _, err := srv.RunFunction(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		nil,
+		{"resource:a", "context:a"},
+		{"resource:a", "resource:b", "context:a", "context:b"},
+	}
+	if diff := cmp.Diff(want, seen); diff != "" {
+		t.Errorf("-want +got\n%s", diff)
+	}
+}
+
+func TestV1Alpha1ServerInput(t *testing.T) {
+	var got *v1alpha1.ServerInput
+	fn := testFunction(func(_ context.Context, req ServerFunctionRequest, _ ServerFunctionResponse) error {
+		got = req.(*RunServerFunctionRequest).V1Alpha1ServerInput()
+		return nil
+	})
+	srv := NewServer(WithFunction("fn", fn, WithVersion("v1")))
+	req := &fnapi.RunFunctionRequest{Input: mustStruct(t, map[string]any{
+		"apiVersion": "server.fn.crossplane.io/v1beta1",
+		"kind":       "ServerInput",
+		"spec": map[string]any{
+			"steps": []any{
+				map[string]any{"functionName": "fn", "functionVersion": "v1", "timeout": "1s", "input": map[string]any{"name": "a"}},
+			},
+		},
+	})}
+	if _, err := srv.RunFunction(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	want := &v1alpha1.ServerInput{
+		Spec: v1alpha1.ServerInputSpec{
+			FunctionName: "fn",
+			Input:        evtv1.JSON{Raw: []byte(`{"name":"a"}`)},
+		},
+	}
+	want.SetGroupVersionKind(v1alpha1.ServerInputGroupVersionKind)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("-want +got\n%s", diff)
+	}
+}
+
+func mustStruct(t *testing.T, in map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"io/fs"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// starlarkFileOptions are the Starlark dialect of scripts. Top-level control
+// flow is allowed, so scripts do not need to define functions.
+var starlarkFileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// StarlarkInput is the input of a StarlarkFunction.
+type StarlarkInput struct {
+	// Script is the source of a Starlark script.
+	Script string `json:"script,omitempty"`
+
+	// ScriptRef is the path of a script in the scripts of the
+	// StarlarkFunction. See StarlarkScripts.
+	ScriptRef string `json:"scriptRef,omitempty"`
+
+	// Values are passed to the script as values.
+	Values map[string]any `json:"values,omitempty"`
+}
+
+// A StarlarkOption configures a StarlarkFunction.
+type StarlarkOption func(fn *StarlarkFunction)
+
+// StarlarkScripts sets the file system scripts can be referenced from by
+// scriptRef, e.g. an embed.FS.
+func StarlarkScripts(fsys fs.FS) StarlarkOption {
+	return func(fn *StarlarkFunction) {
+		fn.scripts = fsys
+	}
+}
+
+// StarlarkMaxSteps limits the number of computation steps of a script.
+func StarlarkMaxSteps(n uint64) StarlarkOption {
+	return func(fn *StarlarkFunction) {
+		fn.maxSteps = n
+	}
+}
+
+// A StarlarkFunction is a ServerFunction that runs the Starlark script of
+// its input.
+//
+// Scripts can read the following globals:
+//
+//	observed  # {"composite": {...}, "resources": {name: {...}}}
+//	desired   # The desired state of previous steps, like observed.
+//	context   # The context of the pipeline.
+//	values    # The values of the input.
+//
+// and use the following builtins to write the desired state:
+//
+//	set_composite(obj)        # Replace the desired composite resource.
+//	set_resource(name, obj)   # Set a desired composed resource.
+//	set_context(key, value)   # Set a context field.
+//	warning(message)          # Return a warning result.
+//	fatal(message)            # Return a fatal result.
+//
+// The output of print is logged. The script is canceled with the call.
+type StarlarkFunction struct {
+	scripts  fs.FS
+	maxSteps uint64
+}
+
+// NewStarlarkFunction returns a new StarlarkFunction.
+func NewStarlarkFunction(opts ...StarlarkOption) *StarlarkFunction {
+	fn := &StarlarkFunction{}
+	for _, o := range opts {
+		o(fn)
+	}
+	return fn
+}
+
+// NewInput implements InputFactory.
+func (f *StarlarkFunction) NewInput() any {
+	return &StarlarkInput{}
+}
+
+// RawInput implements RawInputFunction, since its input contains Starlark scripts.
+func (f *StarlarkFunction) RawInput() bool {
+	return true
+}
+
+// ValidateInput implements InputValidator.
+func (f *StarlarkFunction) ValidateInput(input any) field.ErrorList {
+	in := input.(*StarlarkInput) //nolint:forcetypeassert // NewInput returns a *StarlarkInput.
+	switch {
+	case in.Script == "" && in.ScriptRef == "":
+		return field.ErrorList{field.Required(field.NewPath("script"), "either script or scriptRef is required")}
+	case in.Script != "" && in.ScriptRef != "":
+		return field.ErrorList{field.Forbidden(field.NewPath("scriptRef"), "must not be set together with script")}
+	case in.ScriptRef != "" && f.scripts == nil:
+		return field.ErrorList{field.Forbidden(field.NewPath("scriptRef"), "no scripts are embedded")}
+	}
+	return nil
+}
+
+// Run implements ServerFunction.
+func (f *StarlarkFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	in := &StarlarkInput{}
+	if err := req.GetInput(in); err != nil {
+		return errors.Wrap(err, "cannot decode input")
+	}
+	filename, src := "script.star", []byte(in.Script)
+	if in.ScriptRef != "" {
+		var err error
+		filename = in.ScriptRef
+		if src, err = fs.ReadFile(f.scripts, in.ScriptRef); err != nil {
+			return errors.Wrapf(err, "cannot read script %q", in.ScriptRef)
+		}
+	}
+
+	state, err := newExecRequest(req, res)
+	if err != nil {
+		return err
+	}
+	predeclared := starlark.StringDict{}
+	for name, v := range map[string]any{
+		"observed": map[string]any{"composite": state.Composite, "resources": state.Observed},
+		"desired":  map[string]any{"composite": state.Desired.Composite, "resources": state.Desired.Resources},
+		"context":  state.Context,
+		"values":   in.Values,
+	} {
+		sv, err := toStarlark(v)
+		if err != nil {
+			return errors.Wrapf(err, "cannot convert %s to Starlark", name)
+		}
+		sv.Freeze()
+		predeclared[name] = sv
+	}
+	out := &ExecResponse{}
+	for name, b := range out.starlarkBuiltins() {
+		predeclared[name] = b
+	}
+
+	log := LoggerFrom(ctx)
+	thread := &starlark.Thread{
+		Name: filename,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Debug("Starlark script printed", "script", filename, "message", msg)
+		},
+	}
+	if f.maxSteps > 0 {
+		thread.SetMaxExecutionSteps(f.maxSteps)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	if _, err := starlark.ExecFileOptions(starlarkFileOptions, thread, filename, src, predeclared); err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return errors.Errorf("script %q failed: %s", filename, evalErr.Backtrace())
+		}
+		return errors.Wrapf(err, "script %q failed", filename)
+	}
+	return out.apply(res)
+}
+
+// starlarkBuiltins returns the builtins of a StarlarkFunction that write
+// into this response.
+func (r *ExecResponse) starlarkBuiltins() starlark.StringDict {
+	object := func(fnName string, v starlark.Value) (map[string]any, error) {
+		o, err := fromStarlark(v)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := o.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("%s: expected dict but got %s", fnName, v.Type())
+		}
+		return m, nil
+	}
+	result := func(severity string) *starlark.Builtin {
+		return starlark.NewBuiltin(severity, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var msg string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "message", &msg); err != nil {
+				return nil, err
+			}
+			r.Results = append(r.Results, ExecResult{Severity: severity, Message: msg})
+			return starlark.None, nil
+		})
+	}
+	return starlark.StringDict{
+		"set_composite": starlark.NewBuiltin("set_composite", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var v starlark.Value
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "obj", &v); err != nil {
+				return nil, err
+			}
+			o, err := object(b.Name(), v)
+			if err != nil {
+				return nil, err
+			}
+			r.Composite = o
+			return starlark.None, nil
+		}),
+		"set_resource": starlark.NewBuiltin("set_resource", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var name string
+			var v starlark.Value
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "obj", &v); err != nil {
+				return nil, err
+			}
+			o, err := object(b.Name(), v)
+			if err != nil {
+				return nil, err
+			}
+			if r.Resources == nil {
+				r.Resources = map[string]map[string]any{}
+			}
+			r.Resources[name] = o
+			return starlark.None, nil
+		}),
+		"set_context": starlark.NewBuiltin("set_context", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var key string
+			var v starlark.Value
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key, "value", &v); err != nil {
+				return nil, err
+			}
+			o, err := fromStarlark(v)
+			if err != nil {
+				return nil, err
+			}
+			if r.Context == nil {
+				r.Context = map[string]any{}
+			}
+			r.Context[key] = o
+			return starlark.None, nil
+		}),
+		"warning": result("warning"),
+		"fatal":   result("fatal"),
+	}
+}
+
+// toStarlark converts a JSON value into a Starlark value. Whole numbers are
+// converted to ints.
+func toStarlark(v any) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v)), nil
+		}
+		return starlark.Float(v), nil
+	case []any:
+		l := make([]starlark.Value, len(v))
+		for i, e := range v {
+			sv, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = sv
+		}
+		return starlark.NewList(l), nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := starlark.NewDict(len(v))
+		for _, k := range keys {
+			sv, err := toStarlark(v[k])
+			if err != nil {
+				return nil, err
+			}
+			if err := d.SetKey(starlark.String(k), sv); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	case map[string]map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = e
+		}
+		return toStarlark(m)
+	default:
+		return nil, errors.Errorf("unsupported type %T", v)
+	}
+}
+
+// fromStarlark converts a Starlark value into a JSON value.
+func fromStarlark(v starlark.Value) (any, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, errors.Errorf("int %s is out of range", v)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Indexable: // list and tuple
+		l := make([]any, v.Len())
+		for i := range l {
+			e, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			l[i] = e
+		}
+		return l, nil
+	case *starlark.Dict:
+		m := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			k, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, errors.Errorf("dict key %s is not a string", item[0])
+			}
+			e, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[string(k)] = e
+		}
+		return m, nil
+	default:
+		return nil, errors.Errorf("unsupported Starlark type %s", v.Type())
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestStarlarkFunction(t *testing.T) {
+	scripts := fstest.MapFS{
+		"bucket.star": {Data: []byte(`
+name = observed["composite"]["metadata"]["name"]
+set_resource("bucket", {
+    "apiVersion": "s3.aws.upbound.io/v1beta1",
+    "kind": "Bucket",
+    "spec": {"forProvider": {"region": values["region"]}, "replicas": 2},
+    "metadata": {"name": name + "-bucket"},
+})
+set_context("buckets", len(desired["resources"]) + 1)
+if "region" not in values:
+    warning("no region set")
+`)},
+	}
+	srv := NewServer(WithFunction("starlark", NewStarlarkFunction(StarlarkScripts(scripts), StarlarkMaxSteps(100000)), WithTimeout(time.Second)))
+
+	request := func(t *testing.T, input map[string]any) *fnapi.RunFunctionRequest {
+		t.Helper()
+		req := newTestRequest(t, "starlark", input)
+		req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+			"apiVersion": "example.org/v1",
+			"kind":       "XR",
+			"metadata":   map[string]any{"name": "example"},
+		})}
+		return req
+	}
+
+	cases := map[string]struct {
+		input map[string]any
+		want  *fnapi.RunFunctionResponse
+		err   bool
+	}{
+		"ScriptRef": {
+			input: map[string]any{"scriptRef": "bucket.star", "values": map[string]any{"region": "eu-central-1"}},
+			want: &fnapi.RunFunctionResponse{
+				Desired: &fnapi.State{Resources: map[string]*fnapi.Resource{
+					"bucket": {Resource: mustStruct(t, map[string]any{
+						"apiVersion": "s3.aws.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata":   map[string]any{"name": "example-bucket"},
+						"spec":       map[string]any{"forProvider": map[string]any{"region": "eu-central-1"}, "replicas": 2},
+					})},
+				}},
+				Context: mustStruct(t, map[string]any{"buckets": 1}),
+			},
+		},
+		"InlineScript": {
+			input: map[string]any{"script": `
+set_composite({"status": {"ready": True}})
+fatal("stop")
+`},
+			want: &fnapi.RunFunctionResponse{
+				Desired: &fnapi.State{Composite: &fnapi.Resource{Resource: mustStruct(t, map[string]any{"status": map[string]any{"ready": true}})}},
+				Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_FATAL, Message: "stop"}},
+			},
+		},
+		"MissingScript": {
+			input: map[string]any{},
+			want: &fnapi.RunFunctionResponse{
+				Desired: &fnapi.State{},
+				Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_FATAL, Message: `cannot prepare input of subroutine function "starlark": invalid input: script: Required value: either script or scriptRef is required`}},
+			},
+		},
+		"ScriptError": {
+			input: map[string]any{"script": `set_resource("broken", "not a dict")`},
+			err:   true,
+		},
+		"FrozenGlobals": {
+			input: map[string]any{"script": `values["key"] = "value"`},
+			err:   true,
+		},
+		"MaxSteps": {
+			input: map[string]any{"script": `
+while True:
+    pass
+`},
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := srv.RunFunction(context.Background(), request(t, tc.input))
+			if tc.err != (err != nil) {
+				t.Fatalf("Expected error %t but got %v", tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			if diff := cmp.Diff(tc.want, res, protocmp.Transform(), protocmp.IgnoreFields(&fnapi.RunFunctionResponse{}, "meta")); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStarlarkFunctionResults(t *testing.T) {
+	fn := NewStarlarkFunction()
+	srv := NewServer(
+		WithFunction("starlark", fn, WithDeprecatedAlias("script", "use starlark")),
+		WithFunction("chain", Chain(fn, Parallel(fn, fn))),
+	)
+	warning := func(msg string) *fnapi.Result {
+		return &fnapi.Result{Severity: fnapi.Severity_SEVERITY_WARNING, Message: msg}
+	}
+
+	cases := map[string]struct {
+		spec map[string]any
+		want []*fnapi.Result
+	}{
+		"Steps": {
+			spec: map[string]any{"steps": []any{
+				map[string]any{"functionName": "script", "input": map[string]any{"script": `warning("one")`}},
+				map[string]any{"functionName": "starlark", "input": map[string]any{"script": `warning("two")`}},
+			}},
+			want: []*fnapi.Result{
+				warning("one"),
+				warning(`server function "script" is deprecated: use starlark`),
+				warning("two"),
+			},
+		},
+		"Chain": {
+			spec: map[string]any{"functionName": "chain", "input": map[string]any{"script": `warning("w")`}},
+			want: []*fnapi.Result{warning("w"), warning("w"), warning("w")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnapi.RunFunctionRequest{Input: mustStruct(t, map[string]any{
+				"apiVersion": "server.fn.crossplane.io/v1beta1",
+				"kind":       "ServerInput",
+				"spec":       tc.spec,
+			})}
+			res, err := srv.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, res.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fncontext "github.com/crossplane/function-sdk-go/context"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/pkg/errors"
+)
+
+// Keys of the data that is passed to an InputTemplater.
+const (
+	TemplateKeyComposite   = "xr"
+	TemplateKeyContext     = "context"
+	TemplateKeyEnvironment = "environment"
+)
+
+// An InputTemplater renders a single string value of a ServerFunction input
+// against the given template data.
+//
+// The data contains the observed composite resource, the request context and
+// the environment (see TemplateKeyComposite, TemplateKeyContext and
+// TemplateKeyEnvironment).
+// The returned value replaces the original string in the input and can be of
+// any type that is representable as JSON.
+type InputTemplater func(value string, data map[string]any) (any, error)
+
+// WithInputTemplating enables templating of ServerFunction inputs.
+//
+// Before a ServerFunction is called, every string value of its input is
+// rendered with the given InputTemplater. ServerFunctions registered with
+// WithoutInputTemplating and RawInputFunctions are not rendered.
+func WithInputTemplating(t InputTemplater) ServerOption {
+	return func(server *Server) {
+		server.templater = t
+	}
+}
+
+// WithoutInputTemplating disables templating of the input of a
+// ServerFunction, even if the Server has an InputTemplater.
+func WithoutInputTemplating() FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.rawInput = true
+	}
+}
+
+// A RawInputFunction is a ServerFunction whose input is never rendered by the
+// InputTemplater of a Server, e.g. because the input contains templates or
+// scripts itself.
+type RawInputFunction interface {
+	// RawInput returns true if the input must not be rendered.
+	RawInput() bool
+}
+
+// rendersInput returns true if the input of reg is rendered by an
+// InputTemplater.
+func (reg *registeredFunction) rendersInput() bool {
+	if r, ok := reg.fn.(RawInputFunction); ok && r.RawInput() {
+		return false
+	}
+	return !reg.rawInput
+}
+
+var expressionRegex = regexp.MustCompile(`\$\{\s*([^}]+?)\s*\}`)
+
+// ExpressionTemplater resolves field path expressions like
+// `${xr.spec.region}` against the template data.
+//
+// If a string consists of a single expression only, it is replaced by the
+// referenced value while preserving its type. Otherwise all expressions are
+// substituted by their string representation.
+func ExpressionTemplater(value string, data map[string]any) (any, error) {
+	matches := expressionRegex.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value, nil
+	}
+	paved := fieldpath.Pave(data)
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(value) {
+		path := value[matches[0][2]:matches[0][3]]
+		v, err := paved.GetValue(path)
+		return v, errors.Wrapf(err, "cannot resolve expression %q", path)
+	}
+
+	b := strings.Builder{}
+	last := 0
+	for _, m := range matches {
+		path := value[m[2]:m[3]]
+		v, err := paved.GetValue(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot resolve expression %q", path)
+		}
+		s, err := templateValueString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot render expression %q", path)
+		}
+		b.WriteString(value[last:m[0]])
+		b.WriteString(s)
+		last = m[1]
+	}
+	b.WriteString(value[last:])
+	return b.String(), nil
+}
+
+// GoTemplater renders string values that contain Go template actions like
+// `{{ .xr.spec.region }}` using text/template.
+func GoTemplater(value string, data map[string]any) (any, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	tpl, err := template.New("input").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse template")
+	}
+	b := strings.Builder{}
+	if err := tpl.Execute(&b, data); err != nil {
+		return nil, errors.Wrap(err, "cannot execute template")
+	}
+	return b.String(), nil
+}
+
+// celTemplaterEnv is the CEL environment of CELTemplater.
+var celTemplaterEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable(TemplateKeyComposite, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(TemplateKeyContext, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(TemplateKeyEnvironment, cel.MapType(cel.StringType, cel.DynType)),
+		ext.Strings(),
+		ext.Encoders(),
+	)
+})
+
+// CELTemplater evaluates CEL expressions like
+// `${xr.spec.region.upperAscii()}` against the template data, which is
+// available as the variables xr, context and environment.
+//
+// Like with ExpressionTemplater, a string that consists of a single
+// expression is replaced by its result while preserving its type. Otherwise
+// all expressions are substituted by their string representation.
+func CELTemplater(value string, data map[string]any) (any, error) {
+	exprs, err := celExpressions(value)
+	if err != nil || len(exprs) == 0 {
+		return value, err
+	}
+	env, err := celTemplaterEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create CEL environment")
+	}
+	if len(exprs) == 1 && exprs[0][0] == 0 && exprs[0][1] == len(value) {
+		expr := value[2 : len(value)-1]
+		v, err := evalCEL(env, expr, data)
+		return v, errors.Wrapf(err, "cannot evaluate expression %q", expr)
+	}
+
+	b := strings.Builder{}
+	last := 0
+	for _, e := range exprs {
+		expr := value[e[0]+2 : e[1]-1]
+		v, err := evalCEL(env, expr, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot evaluate expression %q", expr)
+		}
+		s, err := templateValueString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot render expression %q", expr)
+		}
+		b.WriteString(value[last:e[0]])
+		b.WriteString(s)
+		last = e[1]
+	}
+	b.WriteString(value[last:])
+	return b.String(), nil
+}
+
+// celExpressions returns the start and end offsets of all ${...} expressions
+// in s. Unlike expressionRegex it allows braces and quoted strings inside of
+// expressions, e.g. `${{"a": "}"}.a}`.
+func celExpressions(s string) ([][2]int, error) {
+	var exprs [][2]int
+	for pos := 0; ; {
+		i := strings.Index(s[pos:], "${")
+		if i < 0 {
+			return exprs, nil
+		}
+		start := pos + i
+		depth, quote, end := 0, byte(0), -1
+		for j := start + 2; j < len(s) && end < 0; j++ {
+			switch c := s[j]; {
+			case quote != 0 && c == '\\':
+				j++ // Skip the escaped character.
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '{':
+				depth++
+			case c == '}' && depth > 0:
+				depth--
+			case c == '}':
+				end = j + 1
+			}
+		}
+		if end < 0 {
+			return nil, errors.Errorf("unterminated expression at offset %d", start)
+		}
+		exprs = append(exprs, [2]int{start, end})
+		pos = end
+	}
+}
+
+func templateValueString(v any) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case map[string]any, []any:
+		raw, err := json.Marshal(t)
+		return string(raw), err
+	default:
+		return fmt.Sprint(t), nil
+	}
+}
+
+// templateData returns the data an InputTemplater renders against.
+func templateData(req *fnapi.RunFunctionRequest) map[string]any {
+	data := map[string]any{
+		TemplateKeyComposite:   map[string]any{},
+		TemplateKeyContext:     map[string]any{},
+		TemplateKeyEnvironment: map[string]any{},
+	}
+	if xr := req.GetObserved().GetComposite().GetResource(); xr != nil {
+		data[TemplateKeyComposite] = xr.AsMap()
+	}
+	if ctx := req.GetContext(); ctx != nil {
+		data[TemplateKeyContext] = ctx.AsMap()
+		if env, ok := ctx.GetFields()[fncontext.KeyEnvironment]; ok && env.GetStructValue() != nil {
+			data[TemplateKeyEnvironment] = env.GetStructValue().AsMap()
+		}
+	}
+	return data
+}
+
+// renderInput renders all string values of the raw JSON input with the given
+// InputTemplater.
+func renderInput(raw []byte, t InputTemplater, data map[string]any) ([]byte, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	var input any
+	if err := unmarshalNumbers(raw, &input); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal input")
+	}
+	rendered, err := renderValue(input, t, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rendered)
+}
+
+// unmarshalNumbers is like json.Unmarshal but decodes numbers as
+// json.Number, so they are not rounded when raw is encoded again.
+func unmarshalNumbers(raw []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+func renderValue(v any, t InputTemplater, data map[string]any) (any, error) {
+	switch val := v.(type) {
+	case string:
+		return t(val, data)
+	case map[string]any:
+		for k, e := range val {
+			r, err := renderValue(e, t, data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot render field %q", k)
+			}
+			val[k] = r
+		}
+		return val, nil
+	case []any:
+		for i, e := range val {
+			r, err := renderValue(e, t, data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot render index %d", i)
+			}
+			val[i] = r
+		}
+		return val, nil
+	default:
+		return v, nil
+	}
+}
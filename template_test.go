@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenderInput(t *testing.T) {
+	data := map[string]any{
+		TemplateKeyComposite: map[string]any{
+			"metadata": map[string]any{"name": "example"},
+			"spec": map[string]any{
+				"region":   "eu-central-1",
+				"replicas": float64(3),
+			},
+		},
+		TemplateKeyContext:     map[string]any{},
+		TemplateKeyEnvironment: map[string]any{"account": "123"},
+	}
+	type args struct {
+		input     string
+		templater InputTemplater
+	}
+	type want struct {
+		output string
+		err    bool
+	}
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"ExpressionPreservesType": {
+			args: args{
+				input:     `{"replicas":"${xr.spec.replicas}"}`,
+				templater: ExpressionTemplater,
+			},
+			want: want{
+				output: `{"replicas":3}`,
+			},
+		},
+		"ExpressionInterpolation": {
+			args: args{
+				input:     `{"arn":"arn:aws:${xr.spec.region}:${environment.account}","list":["${xr.metadata.name}"]}`,
+				templater: ExpressionTemplater,
+			},
+			want: want{
+				output: `{"arn":"arn:aws:eu-central-1:123","list":["example"]}`,
+			},
+		},
+		"ExpressionNotFound": {
+			args: args{
+				input:     `{"region":"${xr.spec.missing}"}`,
+				templater: ExpressionTemplater,
+			},
+			want: want{
+				err: true,
+			},
+		},
+		"NumbersNotRounded": {
+			args: args{
+				input:     `{"big":12345678901234567890,"float":1.5,"int":9007199254740993,"name":"${xr.metadata.name}"}`,
+				templater: ExpressionTemplater,
+			},
+			want: want{
+				output: `{"big":12345678901234567890,"float":1.5,"int":9007199254740993,"name":"example"}`,
+			},
+		},
+		"GoTemplate": {
+			args: args{
+				input:     `{"name":"{{ .xr.metadata.name }}-{{ .environment.account }}","plain":true}`,
+				templater: GoTemplater,
+			},
+			want: want{
+				output: `{"name":"example-123","plain":true}`,
+			},
+		},
+		"GoTemplateMissingKey": {
+			args: args{
+				input:     `{"name":"{{ .xr.metadata.missing }}"}`,
+				templater: GoTemplater,
+			},
+			want: want{
+				err: true,
+			},
+		},
+		"CELTypePreserved": {
+			args: args{
+				input:     `{"replicas":"${xr.spec.replicas * 2.0}"}`,
+				templater: CELTemplater,
+			},
+			want: want{
+				output: `{"replicas":6}`,
+			},
+		},
+		"CELInterpolated": {
+			args: args{
+				input:     `{"name":"${xr.metadata.name.upperAscii()}-${environment.account}","plain":"text"}`,
+				templater: CELTemplater,
+			},
+			want: want{
+				output: `{"name":"EXAMPLE-123","plain":"text"}`,
+			},
+		},
+		"CELBraces": {
+			args: args{
+				input:     `{"region":"${{\"a\": \"}\", \"b\": xr.spec.region}.b}"}`,
+				templater: CELTemplater,
+			},
+			want: want{
+				output: `{"region":"eu-central-1"}`,
+			},
+		},
+		"CELUnterminated": {
+			args: args{
+				input:     `{"name":"${xr.metadata.name"}`,
+				templater: CELTemplater,
+			},
+			want: want{
+				err: true,
+			},
+		},
+		"CELMissingKey": {
+			args: args{
+				input:     `{"name":"${xr.metadata.missing}"}`,
+				templater: CELTemplater,
+			},
+			want: want{
+				err: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := renderInput([]byte(tc.args.input), tc.args.templater, data)
+			if (err != nil) != tc.want.err {
+				t.Fatalf("Expected error %v but got %v", tc.want.err, err)
+			}
+			if diff := cmp.Diff(tc.want.output, string(out)); err == nil && diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+// rawInputFunction is a RawInputFunction.
+type rawInputFunction struct {
+	testFunction
+}
+
+func (rawInputFunction) RawInput() bool {
+	return true
+}
+
+func TestInputTemplatingOptOut(t *testing.T) {
+	var got map[string]any
+	record := testFunction(func(_ context.Context, req ServerFunctionRequest, _ ServerFunctionResponse) error {
+		got = map[string]any{}
+		return req.GetInput(&got)
+	})
+	srv := NewServer(
+		WithInputTemplating(ExpressionTemplater),
+		WithFunction("templated", record),
+		WithFunction("without", record, WithoutInputTemplating()),
+		WithFunction("raw", rawInputFunction{record}),
+	)
+
+	cases := map[string]struct {
+		function string
+		want     map[string]any
+	}{
+		"Templated": {
+			function: "templated",
+			want:     map[string]any{"name": "example"},
+		},
+		"WithoutInputTemplating": {
+			function: "without",
+			want:     map[string]any{"name": "${xr.metadata.name}"},
+		},
+		"RawInputFunction": {
+			function: "raw",
+			want:     map[string]any{"name": "${xr.metadata.name}"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestRequest(t, tc.function, map[string]any{"name": "${xr.metadata.name}"})
+			req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{"metadata": map[string]any{"name": "example"}})}
+			if _, err := srv.RunFunction(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
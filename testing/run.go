@@ -10,7 +10,7 @@ import (
 	evtv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	server "github.com/mistermx/crossplane-function-server"
-	"github.com/mistermx/crossplane-function-server/apis/v1alpha1"
+	"github.com/mistermx/crossplane-function-server/apis/v1beta1"
 )
 
 type FunctionTest struct {
@@ -60,8 +60,8 @@ func (t *FunctionTest) Run(ctx context.Context) {
 			},
 			Context: t.args.context,
 		},
-		ServerInput: &v1alpha1.ServerInput{
-			Spec: v1alpha1.ServerInputSpec{
+		ServerInput: &v1beta1.ServerInput{
+			Spec: v1beta1.ServerInputSpec{
 				Input: t.args.input,
 			},
 		},
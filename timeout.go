@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type errTimeout struct {
+	name    string
+	timeout time.Duration
+}
+
+func (e errTimeout) Error() string {
+	return fmt.Sprintf("server function %q timed out after %s", e.name, e.timeout)
+}
+
+// IsErrorTimeout returns true if the error was caused by a ServerFunction
+// that exceeded its timeout.
+func IsErrorTimeout(err error) bool {
+	return errors.As(err, &errTimeout{})
+}
+
+// WithTimeout sets the default timeout of a ServerFunction.
+//
+// The timeout can be overridden by the timeout field of a ServerInput. A
+// timeout of zero in the ServerInput keeps the registered timeout.
+func WithTimeout(timeout time.Duration) FunctionOption {
+	return func(fn *registeredFunction) {
+		fn.timeout = timeout
+	}
+}
+
+// runWithTimeout runs fn and aborts it after the given timeout if it is
+// greater than zero.
+//
+// If l is not nil, fn waits for a free slot of l first. The time spent
+// waiting counts against the timeout. The slot is released when fn returns,
+// even if that is after the timeout.
+//
+// The function runs on a copy of res that is only written back if the
+// function returns in time. A function that ignores the cancellation of its
+// context keeps running in the background but cannot modify res anymore.
+func runWithTimeout(ctx context.Context, name string, timeout time.Duration, fn ServerFunction, req ServerFunctionRequest, res *RunServerFunctionResponse, l *limiter) error {
+	fnCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		fnCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	timedOut := func(err error) error {
+		if err != nil && ctx.Err() == nil && errors.Is(fnCtx.Err(), context.DeadlineExceeded) {
+			return errTimeout{name: name, timeout: timeout}
+		}
+		return err
+	}
+
+	release := func() {}
+	if l != nil {
+		var err error
+		if release, err = l.acquire(fnCtx, name); err != nil {
+			return timedOut(err)
+		}
+	}
+	if timeout <= 0 {
+		defer release()
+		return fn.Run(ctx, req, res)
+	}
+
+	fnRes := res.DeepCopy()
+	done := make(chan error, 1)
+	go func() {
+		defer release()
+		done <- fn.Run(fnCtx, req, fnRes)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+		*res = *fnRes
+	case <-fnCtx.Done():
+		err = fnCtx.Err()
+	}
+	return timedOut(err)
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRunFunctionTimeout(t *testing.T) {
+	// sleepFunction sleeps for the duration given in its input and ignores
+	// the cancellation of its context.
+	sleepFunction := testFunction(func(_ context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+		input := struct {
+			Sleep string `json:"sleep"`
+		}{}
+		if err := req.GetInput(&input); err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(input.Sleep)
+		if err != nil {
+			return err
+		}
+		time.Sleep(d)
+		return res.SetContextField("done", true)
+	})
+
+	type args struct {
+		timeout time.Duration
+		input   map[string]any
+	}
+	type want struct {
+		results []*fnapi.Result
+		done    bool
+	}
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"NoTimeout": {
+			args: args{
+				input: map[string]any{"sleep": "10ms"},
+			},
+			want: want{
+				done: true,
+			},
+		},
+		"InTime": {
+			args: args{
+				timeout: time.Second,
+				input:   map[string]any{"sleep": "10ms"},
+			},
+			want: want{
+				done: true,
+			},
+		},
+		"Exceeded": {
+			args: args{
+				timeout: 10 * time.Millisecond,
+				input:   map[string]any{"sleep": "1s"},
+			},
+			want: want{
+				results: []*fnapi.Result{
+					{
+						Severity: fnapi.Severity_SEVERITY_FATAL,
+						Message:  `server function "sleep" timed out after 10ms`,
+					},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := NewServer(WithFunction("sleep", sleepFunction, WithTimeout(tc.args.timeout)))
+			res, err := srv.RunFunction(context.Background(), newTestRequest(t, "sleep", tc.args.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.results, res.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("Results: -want +got\n%s", diff)
+			}
+			if done := res.GetContext().GetFields()["done"].GetBoolValue(); done != tc.want.done {
+				t.Errorf("Expected done %v but got %v", tc.want.done, done)
+			}
+		})
+	}
+}
+
+func TestRunFunctionTimeoutOverride(t *testing.T) {
+	blockFunction := testFunction(func(ctx context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	srv := NewServer(WithFunction("block", blockFunction, WithTimeout(time.Hour)))
+	req := &fnapi.RunFunctionRequest{
+		Input: mustStruct(t, map[string]any{
+			"apiVersion": "server.fn.crossplane.io/v1beta1",
+			"kind":       "ServerInput",
+			"spec": map[string]any{
+				"functionName": "block",
+				"timeout":      "10ms",
+			},
+		}),
+	}
+	res, err := srv.RunFunction(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*fnapi.Result{
+		{
+			Severity: fnapi.Severity_SEVERITY_FATAL,
+			Message:  `server function "block" timed out after 10ms`,
+		},
+	}
+	if diff := cmp.Diff(want, res.GetResults(), protocmp.Transform()); diff != "" {
+		t.Errorf("Results: -want +got\n%s", diff)
+	}
+}
+
+func TestRunFunctionZeroTimeoutOverride(t *testing.T) {
+	blockFunction := testFunction(func(ctx context.Context, _ ServerFunctionRequest, _ ServerFunctionResponse) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	srv := NewServer(WithFunction("block", blockFunction, WithTimeout(10*time.Millisecond)))
+	req := &fnapi.RunFunctionRequest{
+		Input: mustStruct(t, map[string]any{
+			"apiVersion": "server.fn.crossplane.io/v1beta1",
+			"kind":       "ServerInput",
+			"spec": map[string]any{
+				"functionName": "block",
+				"timeout":      "0s",
+			},
+		}),
+	}
+	res, err := srv.RunFunction(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*fnapi.Result{
+		{
+			Severity: fnapi.Severity_SEVERITY_FATAL,
+			Message:  `server function "block" timed out after 10ms`,
+		},
+	}
+	if diff := cmp.Diff(want, res.GetResults(), protocmp.Transform()); diff != "" {
+		t.Errorf("Results: -want +got\n%s", diff)
+	}
+}
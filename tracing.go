@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/mistermx/crossplane-function-server"
+
+// Span attribute keys.
+const (
+	AttributeFunctionName        = attribute.Key("function_server.function.name")
+	AttributeFunctionVersion     = attribute.Key("function_server.function.version")
+	AttributeResultSeverities    = attribute.Key("function_server.result.severities")
+	AttributeCompositeAPIVersion = attribute.Key("crossplane.composite.api_version")
+	AttributeCompositeKind       = attribute.Key("crossplane.composite.kind")
+	AttributeCompositeName       = attribute.Key("crossplane.composite.name")
+)
+
+// WithTracerProvider sets the TracerProvider that is used to create spans
+// for RunFunction calls and ServerFunction invocations.
+//
+// By default the global TracerProvider is used.
+func WithTracerProvider(tp trace.TracerProvider) ServerOption {
+	return func(server *Server) {
+		server.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// NewOTLPTracerProvider returns a TracerProvider that exports spans via
+// OTLP/gRPC.
+//
+// Unless overridden by opts, the exporter is configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables.
+// The TracerProvider must be shut down to flush pending spans.
+func NewOTLPTracerProvider(ctx context.Context, opts ...otlptracegrpc.Option) (*sdktrace.TracerProvider, error) {
+	exp, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create OTLP trace exporter")
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp)), nil
+}
+
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// compositeAttributes returns the span attributes that identify the
+// observed composite resource of a request.
+func compositeAttributes(req *fnapi.RunFunctionRequest) []attribute.KeyValue {
+	xr := req.GetObserved().GetComposite().GetResource().GetFields()
+	return []attribute.KeyValue{
+		AttributeCompositeAPIVersion.String(xr["apiVersion"].GetStringValue()),
+		AttributeCompositeKind.String(xr["kind"].GetStringValue()),
+		AttributeCompositeName.String(xr["metadata"].GetStructValue().GetFields()["name"].GetStringValue()),
+	}
+}
+
+// endSpan records the outcome of an invocation in span and ends it.
+func endSpan(span trace.Span, results []*fnapi.Result, err error) {
+	if len(results) > 0 {
+		severities := make([]string, len(results))
+		for i, r := range results {
+			severities[i] = r.GetSeverity().String()
+		}
+		span.SetAttributes(AttributeResultSeverities.StringSlice(severities))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	warnFunction := testFunction(func(_ context.Context, _ ServerFunctionRequest, res ServerFunctionResponse) error {
+		res.SetNativeResults([]*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_WARNING, Message: "careful"}})
+		return nil
+	})
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	srv := NewServer(
+		WithTracerProvider(tp),
+		WithFunction("warn", warnFunction),
+	)
+
+	req := newTestRequest(t, "warn", nil)
+	req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{
+		"apiVersion": "example.com/v1alpha1",
+		"kind":       "Example",
+		"metadata":   map[string]any{"name": "example"},
+	})}
+	if _, err := srv.RunFunction(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "missing", nil)); err == nil {
+		t.Fatal("Expected error for missing function")
+	}
+
+	type span struct {
+		Name       string
+		Parent     string
+		Attributes map[attribute.Key]attribute.Value
+		Status     codes.Code
+	}
+	names := map[string]string{}
+	got := []span{}
+	for _, s := range exporter.GetSpans() {
+		names[s.SpanContext.SpanID().String()] = s.Name
+	}
+	for _, s := range exporter.GetSpans() {
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, a := range s.Attributes {
+			attrs[a.Key] = a.Value
+		}
+		got = append(got, span{
+			Name:       s.Name,
+			Parent:     names[s.Parent.SpanID().String()],
+			Attributes: attrs,
+			Status:     s.Status.Code,
+		})
+	}
+
+	want := []span{
+		{
+			Name:   "ServerFunction warn",
+			Parent: "RunFunction",
+			Attributes: map[attribute.Key]attribute.Value{
+				AttributeFunctionName:     attribute.StringValue("warn"),
+				AttributeFunctionVersion:  attribute.StringValue(""),
+				AttributeResultSeverities: attribute.StringSliceValue([]string{"SEVERITY_WARNING"}),
+			},
+		},
+		{
+			Name: "RunFunction",
+			Attributes: map[attribute.Key]attribute.Value{
+				AttributeCompositeAPIVersion: attribute.StringValue("example.com/v1alpha1"),
+				AttributeCompositeKind:       attribute.StringValue("Example"),
+				AttributeCompositeName:       attribute.StringValue("example"),
+				AttributeResultSeverities:    attribute.StringSliceValue([]string{"SEVERITY_WARNING"}),
+			},
+		},
+		{
+			Name:   "ServerFunction missing",
+			Parent: "RunFunction",
+			Attributes: map[attribute.Key]attribute.Value{
+				AttributeFunctionName:    attribute.StringValue("missing"),
+				AttributeFunctionVersion: attribute.StringValue(""),
+			},
+			Status: codes.Error,
+		},
+		{
+			Name: "RunFunction",
+			Attributes: map[attribute.Key]attribute.Value{
+				AttributeCompositeAPIVersion: attribute.StringValue(""),
+				AttributeCompositeKind:       attribute.StringValue(""),
+				AttributeCompositeName:       attribute.StringValue(""),
+			},
+			Status: codes.Error,
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Errorf("Spans: -want +got\n%s", diff)
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Exports of a WebAssembly module that are used by a WASMFunction.
+const (
+	wasmExportAllocate = "allocate"
+	wasmExportRun      = "run"
+)
+
+// A WASMFunction is a ServerFunction that runs a WebAssembly module in a
+// sandbox.
+//
+// The module must export its memory and the following functions:
+//
+//	allocate(size i32) i32
+//	run(ptr i32, len i32) i64
+//
+// For every call a new instance of the module is created. The ExecRequest is
+// written as JSON to the memory returned by allocate and passed to run,
+// which returns the JSON encoded ExecResponse as pointer (upper 32 bits) and
+// length (lower 32 bits). A trap fails the call.
+//
+// WASI is available to the module. Its stdout and stderr are logged, it has
+// no access to the file system or network.
+type WASMFunction struct {
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+}
+
+// DefaultWASMMemoryLimitPages is the default maximum memory of a
+// WASMFunction in pages of 64 KiB, i.e. 64 MiB.
+const DefaultWASMMemoryLimitPages = 1024
+
+// A WASMOption configures a WASMFunction.
+type WASMOption func(o *wasmOptions)
+
+type wasmOptions struct {
+	memoryLimitPages uint32
+}
+
+// WASMMemoryLimitPages limits the memory of every instance of the module to
+// the given number of pages of 64 KiB. Defaults to
+// DefaultWASMMemoryLimitPages.
+func WASMMemoryLimitPages(pages uint32) WASMOption {
+	return func(o *wasmOptions) {
+		o.memoryLimitPages = pages
+	}
+}
+
+// NewWASMFunction compiles the given WebAssembly module into a
+// WASMFunction. Call Close to release its resources.
+//
+// A module whose minimum memory exceeds the memory limit is rejected.
+func NewWASMFunction(ctx context.Context, wasm []byte, opts ...WASMOption) (*WASMFunction, error) {
+	o := &wasmOptions{memoryLimitPages: DefaultWASMMemoryLimitPages}
+	for _, fn := range opts {
+		fn(o)
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(o.memoryLimitPages))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		_ = r.Close(ctx)
+		return nil, errors.Wrap(err, "cannot instantiate WASI")
+	}
+	m, err := r.CompileModule(ctx, wasm)
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, errors.Wrap(err, "cannot compile WebAssembly module")
+	}
+	if len(m.ExportedMemories()) == 0 {
+		_ = r.Close(ctx)
+		return nil, errors.New("WebAssembly module does not export its memory")
+	}
+	for _, name := range []string{wasmExportAllocate, wasmExportRun} {
+		if _, ok := m.ExportedFunctions()[name]; !ok {
+			_ = r.Close(ctx)
+			return nil, errors.Errorf("WebAssembly module does not export function %q", name)
+		}
+	}
+	return &WASMFunction{runtime: r, module: m}, nil
+}
+
+// Close releases the resources of the WASMFunction.
+func (f *WASMFunction) Close(ctx context.Context) error {
+	return f.runtime.Close(ctx)
+}
+
+// Run implements ServerFunction.
+func (f *WASMFunction) Run(ctx context.Context, req ServerFunctionRequest, res ServerFunctionResponse) error {
+	in, err := newExecRequest(req, res)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode request")
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	defer func() {
+		log := LoggerFrom(ctx)
+		for _, l := range outputLines(stdout.Bytes()) {
+			log.Debug("WebAssembly module wrote to stdout", "line", l)
+		}
+		for _, l := range outputLines(stderr.Bytes()) {
+			log.Debug("WebAssembly module wrote to stderr", "line", l)
+		}
+	}()
+	mod, err := f.runtime.InstantiateModule(ctx, f.module, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStdout(stdout).
+		WithStderr(stderr))
+	if err != nil {
+		return errors.Wrap(err, "cannot instantiate WebAssembly module")
+	}
+	defer mod.Close(ctx) //nolint:errcheck // The instance is not used anymore.
+
+	ptr, err := mod.ExportedFunction(wasmExportAllocate).Call(ctx, uint64(len(raw)))
+	if err != nil {
+		return errors.Wrap(err, "cannot allocate request memory")
+	}
+	if !mod.Memory().Write(uint32(ptr[0]), raw) {
+		return errors.New("allocated request memory is out of range")
+	}
+	out, err := mod.ExportedFunction(wasmExportRun).Call(ctx, ptr[0], uint64(len(raw)))
+	if err != nil {
+		return errors.Wrap(err, "cannot run WebAssembly module")
+	}
+	resRaw, ok := mod.Memory().Read(uint32(out[0]>>32), uint32(out[0]))
+	if !ok {
+		return errors.New("response of WebAssembly module is out of range")
+	}
+
+	wasmRes := &ExecResponse{}
+	if err := json.Unmarshal(resRaw, wasmRes); err != nil {
+		return errors.Wrap(err, "cannot decode response of WebAssembly module")
+	}
+	return wasmRes.apply(res)
+}
+
+// PluginManifest declares WebAssembly modules to register as
+// ServerFunctions.
+type PluginManifest struct {
+	// Plugins to register.
+	Plugins []PluginConfig `json:"plugins"`
+}
+
+// PluginConfig declares a WebAssembly module to register as ServerFunction.
+type PluginConfig struct {
+	// Name the ServerFunction is registered with.
+	Name string `json:"name"`
+
+	// Path of the WebAssembly module. Relative paths are relative to the
+	// manifest.
+	Path string `json:"path"`
+
+	// Version of the ServerFunction, if any.
+	Version string `json:"version,omitempty"`
+
+	// Timeout of the ServerFunction, if any.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// MemoryLimitPages limits the memory of the module in pages of 64 KiB.
+	// Defaults to DefaultWASMMemoryLimitPages.
+	MemoryLimitPages *uint32 `json:"memoryLimitPages,omitempty"`
+}
+
+// newPluginFunction creates the WASMFunction of a plugin. It is replaced by
+// tests.
+var newPluginFunction = NewWASMFunction
+
+// LoadPlugins compiles the WebAssembly modules declared by the
+// PluginManifest at path and returns ServerOptions that register them as
+// WASMFunctions, together with a function that closes all of them.
+func LoadPlugins(ctx context.Context, path string) (_ []ServerOption, _ func(context.Context) error, err error) {
+	raw, err := os.ReadFile(path) //nolint:gosec // Reading user supplied files is intended.
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot read plugin manifest %q", path)
+	}
+	manifest := &PluginManifest{}
+	if err := sigsyaml.UnmarshalStrict(raw, manifest); err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot decode plugin manifest %q", path)
+	}
+
+	opts := make([]ServerOption, 0, len(manifest.Plugins))
+	loaded := make([]*WASMFunction, 0, len(manifest.Plugins))
+	closePlugins := func(ctx context.Context) error {
+		var first error
+		for _, fn := range loaded {
+			if err := fn.Close(ctx); err != nil && first == nil {
+				first = err
+			}
+		}
+		return errors.Wrap(first, "cannot close plugins")
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Release the plugins that were loaded before the error.
+		_ = closePlugins(ctx)
+	}()
+	names := map[string]bool{}
+	for _, p := range manifest.Plugins {
+		if p.Name == "" {
+			return nil, nil, errors.Errorf("plugin %q has no name", p.Path)
+		}
+		if names[p.Name] {
+			return nil, nil, errors.Errorf("plugin %q is declared twice", p.Name)
+		}
+		names[p.Name] = true
+
+		modPath := p.Path
+		if !filepath.IsAbs(modPath) {
+			modPath = filepath.Join(filepath.Dir(path), modPath)
+		}
+		wasm, err := os.ReadFile(modPath) //nolint:gosec // Reading user supplied files is intended.
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot read plugin %q", p.Name)
+		}
+		var wasmOpts []WASMOption
+		if p.MemoryLimitPages != nil {
+			wasmOpts = append(wasmOpts, WASMMemoryLimitPages(*p.MemoryLimitPages))
+		}
+		fn, err := newPluginFunction(ctx, wasm, wasmOpts...)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot load plugin %q", p.Name)
+		}
+		loaded = append(loaded, fn)
+
+		fnOpts := []FunctionOption{WithVersion(p.Version)}
+		if p.Timeout != nil {
+			fnOpts = append(fnOpts, WithTimeout(p.Timeout.Duration))
+		}
+		opts = append(opts, WithFunction(p.Name, fn, fnOpts...))
+	}
+	return opts, closePlugins, nil
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// WebAssembly instructions used by wasmModule.
+const (
+	wasmUnreachable    = 0x00
+	wasmEnd            = 0x0b
+	wasmLocalGet       = 0x20
+	wasmGlobalGet      = 0x23
+	wasmGlobalSet      = 0x24
+	wasmIf             = 0x04
+	wasmBlockVoid      = 0x40
+	wasmMemoryGrow     = 0x40
+	wasmI32Const       = 0x41
+	wasmI32Eq          = 0x46
+	wasmI32Add         = 0x6a
+	wasmI64Const       = 0x42
+	wasmI64Or          = 0x84
+	wasmI64Shl         = 0x86
+	wasmI64ExtendI32U  = 0xad
+	wasmSectionType    = 0x01
+	wasmSectionFunc    = 0x03
+	wasmSectionMemory  = 0x05
+	wasmSectionGlobal  = 0x06
+	wasmSectionExport  = 0x07
+	wasmSectionCode    = 0x0a
+	wasmTypeFunc       = 0x60
+	wasmTypeI32        = 0x7f
+	wasmTypeI64        = 0x7e
+	wasmExportKindFunc = 0x00
+	wasmExportKindMem  = 0x02
+)
+
+// wasmEcho is the body of a run function that returns its request as
+// response.
+var wasmEcho = []byte{
+	wasmLocalGet, 0, wasmI64ExtendI32U, wasmI64Const, 32, wasmI64Shl,
+	wasmLocalGet, 1, wasmI64ExtendI32U, wasmI64Or,
+}
+
+// wasmGrow is the body of a run function that grows the memory by two pages,
+// traps if that fails and returns its request as response otherwise.
+var wasmGrow = append([]byte{
+	wasmI32Const, 2, wasmMemoryGrow, 0,
+	wasmI32Const, 0x7f, wasmI32Eq, wasmIf, wasmBlockVoid, wasmUnreachable, wasmEnd,
+}, wasmEcho...)
+
+// wasmModule returns a WebAssembly module that implements the ABI of
+// WASMFunction with a bump allocator and the given body of its run function.
+func wasmModule(run []byte) []byte {
+	vec := func(items ...[]byte) []byte {
+		out := []byte{byte(len(items))}
+		for _, i := range items {
+			out = append(out, i...)
+		}
+		return out
+	}
+	section := func(id byte, content []byte) []byte {
+		return append([]byte{id, byte(len(content))}, content...)
+	}
+	name := func(n string) []byte {
+		return append([]byte{byte(len(n))}, n...)
+	}
+	body := func(instrs []byte) []byte {
+		b := append([]byte{0}, append(instrs, wasmEnd)...) // No locals.
+		return append([]byte{byte(len(b))}, b...)
+	}
+
+	m := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	m = append(m, section(wasmSectionType, vec(
+		[]byte{wasmTypeFunc, 1, wasmTypeI32, 1, wasmTypeI32},
+		[]byte{wasmTypeFunc, 2, wasmTypeI32, wasmTypeI32, 1, wasmTypeI64},
+	))...)
+	m = append(m, section(wasmSectionFunc, vec([]byte{0}, []byte{1}))...)
+	m = append(m, section(wasmSectionMemory, vec([]byte{0x00, 1}))...)
+	// The heap starts at 1024 (0x80 0x08 in LEB128).
+	m = append(m, section(wasmSectionGlobal, vec([]byte{wasmTypeI32, 1, 0x41, 0x80, 0x08, wasmEnd}))...)
+	m = append(m, section(wasmSectionExport, vec(
+		append(name("memory"), wasmExportKindMem, 0),
+		append(name(wasmExportAllocate), wasmExportKindFunc, 0),
+		append(name(wasmExportRun), wasmExportKindFunc, 1),
+	))...)
+	m = append(m, section(wasmSectionCode, vec(
+		body([]byte{wasmGlobalGet, 0, wasmGlobalGet, 0, wasmLocalGet, 0, wasmI32Add, wasmGlobalSet, 0}),
+		body(run),
+	))...)
+	return m
+}
+
+func TestWASMFunction(t *testing.T) {
+	dir := t.TempDir()
+	for file, wasm := range map[string][]byte{
+		"echo.wasm": wasmModule(wasmEcho),
+		"trap.wasm": wasmModule([]byte{wasmUnreachable}),
+		"grow.wasm": wasmModule(wasmGrow),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, file), wasm, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(dir, "plugins.yaml")
+	if err := os.WriteFile(manifest, []byte(`
+plugins:
+- name: echo
+  path: echo.wasm
+  version: v1
+  timeout: 5s
+- name: trap
+  path: trap.wasm
+- name: grow
+  path: grow.wasm
+- name: limited
+  path: grow.wasm
+  memoryLimitPages: 2
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, closePlugins, err := LoadPlugins(context.Background(), manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(opts...)
+
+	wantInfo := []FunctionInfo{
+		{Name: "echo", Version: "v1", Timeout: 5 * time.Second},
+		{Name: "grow"},
+		{Name: "limited"},
+		{Name: "trap"},
+	}
+	if diff := cmp.Diff(wantInfo, srv.Functions()); diff != "" {
+		t.Errorf("Functions: -want +got\n%s", diff)
+	}
+
+	t.Run("Echo", func(t *testing.T) {
+		// The echoed request sets the observed composite as desired
+		// composite and its context as context.
+		req := newTestRequest(t, "echo", map[string]any{"greeting": "hello"})
+		req.Observed.Composite = &fnapi.Resource{Resource: mustStruct(t, map[string]any{"kind": "XR"})}
+		req.Context = mustStruct(t, map[string]any{"key": "value"})
+		res, err := srv.RunFunction(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &fnapi.RunFunctionResponse{
+			Desired: &fnapi.State{Composite: &fnapi.Resource{Resource: mustStruct(t, map[string]any{"kind": "XR"})}},
+			Context: mustStruct(t, map[string]any{"key": "value"}),
+		}
+		if diff := cmp.Diff(want, res, protocmp.Transform()); diff != "" {
+			t.Errorf("-want +got\n%s", diff)
+		}
+	})
+
+	t.Run("Trap", func(t *testing.T) {
+		if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "trap", nil)); err == nil {
+			t.Error("Expected trap to fail the call")
+		}
+	})
+
+	t.Run("MemoryLimit", func(t *testing.T) {
+		if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "grow", nil)); err != nil {
+			t.Errorf("Expected memory to grow within the default limit but got %v", err)
+		}
+		if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "limited", nil)); err == nil {
+			t.Error("Expected memory to be limited")
+		}
+		if _, err := NewWASMFunction(context.Background(), wasmModule(wasmEcho), WASMMemoryLimitPages(0)); err == nil {
+			t.Error("Expected module exceeding the memory limit to be rejected")
+		}
+	})
+
+	t.Run("InvalidModule", func(t *testing.T) {
+		if _, err := NewWASMFunction(context.Background(), []byte("not wasm")); err == nil {
+			t.Error("Expected invalid module to be rejected")
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		if err := closePlugins(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.RunFunction(context.Background(), newTestRequest(t, "echo", nil)); err == nil {
+			t.Error("Expected closed plugin to fail the call")
+		}
+	})
+}
+
+func TestLoadPluginsCloseOnError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "echo.wasm"), wasmModule(wasmEcho), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(dir, "plugins.yaml")
+	if err := os.WriteFile(manifest, []byte(`
+plugins:
+- name: echo
+  path: echo.wasm
+- name: missing
+  path: missing.wasm
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded []*WASMFunction
+	newPluginFunction = func(ctx context.Context, wasm []byte, opts ...WASMOption) (*WASMFunction, error) {
+		fn, err := NewWASMFunction(ctx, wasm, opts...)
+		loaded = append(loaded, fn)
+		return fn, err
+	}
+	t.Cleanup(func() { newPluginFunction = NewWASMFunction })
+
+	if _, _, err := LoadPlugins(context.Background(), manifest); err == nil {
+		t.Fatal("Expected missing plugin to fail loading")
+	}
+	if len(loaded) != 1 {
+		t.Fatalf("Expected 1 loaded plugin but got %d", len(loaded))
+	}
+	// A closed plugin cannot be run anymore.
+	req := &RunServerFunctionRequest{Req: newTestRequest(t, "echo", nil)}
+	req.ServerInput, _ = parseServerInput(req.Req.GetInput())
+	if err := loaded[0].Run(context.Background(), req, &RunServerFunctionResponse{}); err == nil {
+		t.Error("Expected loaded plugin to be closed")
+	}
+}